@@ -0,0 +1,99 @@
+package bufrw
+
+import "io"
+
+// WritePackedBools writes zero or more boolean values to w, packing eight
+// values into each byte. Value i is stored in bit i%8 of byte i/8, where
+// bit 0 is the least significant bit.
+func (buf *Buffer) WritePackedBools(w io.Writer, val ...bool) error {
+	if err := buf.WriteNullableLen(w, len(val), val == nil); err != nil {
+		return err
+	}
+	b := buf.borrow((len(val) + 7) / 8)
+	for i := range b {
+		b[i] = 0
+	}
+	for i, v := range val {
+		if v {
+			b[i/8] |= 1 << (i % 8)
+		}
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadPackedBools reads zero or more boolean values from r, where r reads
+// from a source that has used WritePackedBools to write the boolean values.
+func (buf *Buffer) ReadPackedBools(r io.Reader) ([]bool, error) {
+	return buf.ReadPackedBoolsMax(r, 0)
+}
+
+// ReadPackedBoolsMax is like ReadPackedBools, but fails with
+// ErrLengthExceeded before reading more than max boolean values. A max of
+// zero or less means only the limits of the buffer apply.
+func (buf *Buffer) ReadPackedBoolsMax(r io.Reader, max int) ([]bool, error) {
+	n, b, err := buf.readBits(r, minLimit(max, buf.limits.MaxSliceLen))
+	if err != nil || n == nilLen {
+		return nil, err
+	}
+	values := make([]bool, n)
+	for i := range values {
+		values[i] = b[i/8]&(1<<(i%8)) != 0
+	}
+	return values, nil
+}
+
+// WriteBitset writes a bitset of 64 bits per word to w, using the same
+// encoding as WritePackedBools for the len(words)*64 bits of the bitset.
+// Bit i of the bitset is bit i%64 of words[i/64].
+func (buf *Buffer) WriteBitset(w io.Writer, words ...uint64) error {
+	if err := buf.WriteNullableLen(w, len(words)*64, words == nil); err != nil {
+		return err
+	}
+	b := buf.borrow(len(words) * 8)
+	for i, word := range words {
+		for j := 0; j < 8; j++ {
+			b[i*8+j] = byte(word >> (j * 8))
+		}
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadBitset reads a bitset from r, where r reads from a source that has
+// used WriteBitset or WritePackedBools to write the bits. If the number of
+// bits is not a multiple of 64, the last word is padded with zero bits.
+func (buf *Buffer) ReadBitset(r io.Reader) ([]uint64, error) {
+	n, b, err := buf.readBits(r, buf.limits.MaxSliceLen)
+	if err != nil || n == nilLen {
+		return nil, err
+	}
+	words := make([]uint64, (n+63)/64)
+	for i, c := range b {
+		words[i/8] |= uint64(c) << (i % 8 * 8)
+	}
+	if n%64 != 0 {
+		words[len(words)-1] &= 1<<(n%64) - 1
+	}
+	return words, nil
+}
+
+// readBits reads the number of bits written by WritePackedBools or
+// WriteBitset, which must be at most max, and the bytes holding them. The
+// returned byte slice is borrowed from the buffer. The number of bits is
+// nilLen if nil was written.
+func (buf *Buffer) readBits(r io.Reader, max int) (int, []byte, error) {
+	n, err := buf.readNullableLen(r, max, 0)
+	if err != nil || n == nilLen {
+		return n, nil, err
+	}
+	size := (n + 7) / 8
+	if err := checkRemaining(r, int64(size)); err != nil {
+		return 0, nil, err
+	}
+	b, err := buf.Read(r, size)
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, b, nil
+}
@@ -0,0 +1,75 @@
+package bufrw
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestBufferReadWritePackedBools(t *testing.T) {
+	tests := [][]bool{
+		{},
+		{true},
+		{false, true, true, false, true, false, false, true, true},
+	}
+	for i := 0; i < 100; i++ {
+		values := make([]bool, rand.Intn(200))
+		for j := range values {
+			values[j] = rand.Int()%2 == 0
+		}
+		tests = append(tests, values)
+	}
+	testReadWrite(t, tests, func(buf *Buffer, w io.Writer, val []bool) error {
+		return buf.WritePackedBools(w, val...)
+	}, (*Buffer).ReadPackedBools)
+
+	var buf Buffer
+	var w bytes.Buffer
+	if err := buf.WritePackedBools(&w, true, false, false, false, false, false, false, false, true); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0, 0, 0, 9, 0x01, 0x01}; !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("WritePackedBools = %x, want %x", w.Bytes(), want)
+	}
+}
+
+func TestBufferReadWriteBitset(t *testing.T) {
+	tests := [][]uint64{
+		{},
+		{1},
+		{0x8000000000000001, 0, 0xdeadbeefcafebabe},
+	}
+	testReadWrite(t, tests, func(buf *Buffer, w io.Writer, val []uint64) error {
+		return buf.WriteBitset(w, val...)
+	}, (*Buffer).ReadBitset)
+}
+
+func TestBufferBitsetPackedBoolsCompatible(t *testing.T) {
+	var buf Buffer
+	var w bytes.Buffer
+	if err := buf.WriteBitset(&w, 0x05, 1<<63); err != nil {
+		t.Fatal(err)
+	}
+	bools, err := buf.ReadPackedBools(&w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]bool, 128)
+	want[0], want[2], want[127] = true, true, true
+	if !reflect.DeepEqual(bools, want) {
+		t.Errorf("ReadPackedBools of bitset = %v, want %v", bools, want)
+	}
+
+	if err := buf.WritePackedBools(&w, true, false, true); err != nil {
+		t.Fatal(err)
+	}
+	words, err := buf.ReadBitset(&w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{0x05}; !reflect.DeepEqual(words, want) {
+		t.Errorf("ReadBitset of packed bools = %x, want %x", words, want)
+	}
+}
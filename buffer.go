@@ -12,22 +12,68 @@ import (
 
 // Buffer provides utility methods for reading and writing binary data
 // with minimal memory allocations.
+//
+// The zero value is an empty buffer ready to use, whose internal byte
+// buffer grows without bound.
 type Buffer struct {
 	b       []byte
+	size    int
 	maxSize int
+	limits  Limits
+	depth   int
+
+	order         binary.ByteOrder
+	varintLengths bool
+	nullable      bool
+	timeMode      TimeMode
+	registry      *Registry
 }
 
 // NewBuffer creates a new buffer with an internal byte buffer of the
-// specified size
+// specified size, which grows without bound. Use NewBufferSize to limit
+// its growth.
 func NewBuffer(size int) *Buffer {
-	return &Buffer{b: make([]byte, size), maxSize: size}
+	return &Buffer{b: make([]byte, size), size: size}
 }
 
 // NewBufferSize creates a new buffer with an internal byte buffer of the
-// specified size
-// func NewBufferSize(size, maxSize int) *Buffer {
-// 	return &Buffer{b: make([]byte, size), maxSize: maxSize}
-// }
+// specified size, which may grow up to maxSize bytes. Larger reads and
+// writes are served from temporary byte slices. A maxSize of zero or less
+// lets the internal byte buffer grow without bound.
+func NewBufferSize(size, maxSize int) *Buffer {
+	if maxSize > 0 && size > maxSize {
+		size = maxSize
+	}
+	return &Buffer{b: make([]byte, size), size: size, maxSize: maxSize}
+}
+
+// Shrink releases the memory the internal byte buffer has grown into,
+// returning it to the size the buffer was created with.
+func (buf *Buffer) Shrink() {
+	if len(buf.b) > buf.size {
+		buf.b = make([]byte, buf.size)
+	}
+}
+
+// Reset releases the internal byte buffer entirely. The buffer remains
+// usable and allocates a new internal byte buffer on demand.
+func (buf *Buffer) Reset() {
+	buf.b = nil
+}
+
+// SetByteOrder sets the byte order of the fixed-width values written and
+// read by the buffer. The default byte order is big-endian.
+func (buf *Buffer) SetByteOrder(order binary.ByteOrder) {
+	buf.order = order
+}
+
+// byteOrder returns the byte order of the buffer.
+func (buf *Buffer) byteOrder() binary.ByteOrder {
+	if buf.order == nil {
+		return binary.BigEndian
+	}
+	return buf.order
+}
 
 // borrow returns a byte slice of length n, borrowing from the internal
 // byte slice of the buffer. If n is larger than the internal buffer,
@@ -46,7 +92,12 @@ func (buf *Buffer) borrow(n int) []byte {
 		return buf.b
 	}
 	if n > len(buf.b) {
-		// TODO: respect maxSize
+		if buf.maxSize > 0 && n > buf.maxSize {
+			if len(buf.b) < buf.maxSize {
+				buf.b = make([]byte, buf.maxSize)
+			}
+			return make([]byte, n)
+		}
 		buf.b = make([]byte, n)
 	}
 	return buf.b[:n]
@@ -61,6 +112,75 @@ func (buf *Buffer) Read(r io.Reader, n int) ([]byte, error) {
 	return b, nil
 }
 
+// chunkSize is the maximum number of bytes the plural methods encode into
+// the borrowed byte slice before writing them, or decode from it after
+// reading them.
+const chunkSize = 4096
+
+// chunkLen returns the number of values of size bytes each that fit in
+// a chunk.
+func (buf *Buffer) chunkLen(size int) int {
+	n := chunkSize
+	if buf.maxSize > 0 && buf.maxSize < n {
+		n = buf.maxSize
+	}
+	if n < size {
+		return 1
+	}
+	return n / size
+}
+
+// writeValues writes the length of val followed by its values to w,
+// encoding values of size bytes each with put. The values are written in
+// chunks, using few calls to w.Write.
+func writeValues[T any](buf *Buffer, w io.Writer, val []T, size int, put func(b []byte, v T)) error {
+	if err := buf.WriteNullableLen(w, len(val), val == nil); err != nil {
+		return err
+	}
+	per := buf.chunkLen(size)
+	for len(val) > 0 {
+		n := len(val)
+		if n > per {
+			n = per
+		}
+		b := buf.borrow(n * size)
+		for i, v := range val[:n] {
+			put(b[i*size:], v)
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		val = val[n:]
+	}
+	return nil
+}
+
+// readValues reads values from r, where r reads from a source that has
+// used writeValues to write the values. Each value of size bytes is
+// decoded with get.
+func readValues[T any](buf *Buffer, r io.Reader, size int, get func(b []byte) T) ([]T, error) {
+	n, err := buf.readNullableLen(r, buf.limits.MaxSliceLen, size)
+	if err != nil || n == nilLen {
+		return nil, err
+	}
+	values := make([]T, n)
+	per := buf.chunkLen(size)
+	for i := 0; i < n; i += per {
+		m := n - i
+		if m > per {
+			m = per
+		}
+		b, err := buf.Read(r, m*size)
+		if err != nil {
+			return nil, err
+		}
+		for j := range values[i : i+m] {
+			values[i+j] = get(b[j*size:])
+		}
+	}
+	return values, nil
+}
+
 // WriteBool writes a boolean value to w.
 func (buf *Buffer) WriteBool(w io.Writer, val bool) error {
 	if val {
@@ -78,31 +198,19 @@ func (buf *Buffer) ReadBool(r io.Reader) (bool, error) {
 
 // WriteBools writes zero or more boolean values to w.
 func (buf *Buffer) WriteBools(w io.Writer, val ...bool) error {
-	if err := buf.WriteInt(w, len(val)); err != nil {
-		return err
-	}
-	for _, v := range val {
-		if err := buf.WriteBool(w, v); err != nil {
-			return err
+	return writeValues(buf, w, val, 1, func(b []byte, v bool) {
+		if v {
+			b[0] = 1
+		} else {
+			b[0] = 0
 		}
-	}
-	return nil
+	})
 }
 
 // ReadBools reads zero or more boolean values from r, where r reads
 // from a source that has used WriteBools to write the boolean values.
 func (buf *Buffer) ReadBools(r io.Reader) ([]bool, error) {
-	n, err := buf.ReadInt(r)
-	if err != nil {
-		return nil, err
-	}
-	values := make([]bool, n)
-	for i := 0; i < n; i++ {
-		if values[i], err = buf.ReadBool(r); err != nil {
-			return nil, err
-		}
-	}
-	return values, nil
+	return readValues(buf, r, 1, func(b []byte) bool { return b[0] == 1 })
 }
 
 // WriteByteValue writes a single byte to w.
@@ -124,7 +232,7 @@ func (buf *Buffer) ReadByteValue(r io.Reader) (byte, error) {
 
 // WriteByteValues writes zero or more single byte values to w.
 func (buf *Buffer) WriteByteValues(w io.Writer, val ...byte) error {
-	if err := buf.WriteInt(w, len(val)); err != nil {
+	if err := buf.WriteNullableLen(w, len(val), val == nil); err != nil {
 		return err
 	}
 	_, err := w.Write(val)
@@ -134,30 +242,75 @@ func (buf *Buffer) WriteByteValues(w io.Writer, val ...byte) error {
 // ReadByteValues reads zero or more single byte values from r, where r reads
 // from a source that has used WriteByteValues to write the byte values.
 func (buf *Buffer) ReadByteValues(r io.Reader) ([]byte, error) {
-	n, err := buf.ReadInt(r)
-	if err != nil {
+	n, err := buf.readNullableLen(r, buf.limits.MaxSliceLen, 1)
+	if err != nil || n == nilLen {
 		return nil, err
 	}
 	values := make([]byte, n)
-	for i := 0; i < n; i++ {
-		if values[i], err = buf.ReadByteValue(r); err != nil {
-			return nil, err
-		}
+	if _, err := io.ReadFull(r, values); err != nil {
+		return nil, err
 	}
 	return values, nil
 }
 
+// ReadByteValuesInto reads zero or more single byte values from r like
+// ReadByteValues, but reads them into dst if it has sufficient capacity.
+// The returned slice holds the values read, and is nil if a nil slice was
+// written.
+func (buf *Buffer) ReadByteValuesInto(r io.Reader, dst []byte) ([]byte, error) {
+	n, err := buf.readNullableLen(r, buf.limits.MaxSliceLen, 1)
+	if err != nil || n == nilLen {
+		return nil, err
+	}
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+	if _, err := io.ReadFull(r, dst); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// ReadByteValuesBorrowed reads zero or more single byte values from r like
+// ReadByteValues, but returns a slice borrowed from the buffer. The slice
+// is only valid until the next call to a method of the buffer.
+func (buf *Buffer) ReadByteValuesBorrowed(r io.Reader) ([]byte, error) {
+	n, err := buf.readNullableLen(r, buf.limits.MaxSliceLen, 1)
+	if err != nil || n == nilLen {
+		return nil, err
+	}
+	return buf.Read(r, n)
+}
+
+var errIntRange = errors.New("util/binary/Buffer.WriteInt(): value must be in int32 range")
+
+// encodeInt maps an int in the int32 range to its unsigned representation
+// written by WriteInt.
+func encodeInt(val int) uint32 {
+	if val < 0 {
+		val = -val + math.MaxInt32
+	}
+	return uint32(val)
+}
+
+// decodeInt maps the unsigned representation written by WriteInt back to
+// an int.
+func decodeInt(val uint32) int {
+	if val <= math.MaxInt32 {
+		return int(val)
+	}
+	return -int(val - math.MaxInt32)
+}
+
 // WriteInt writes an int to w. The value must be within the range of
 // a +/- 32 bit integer.
 func (buf *Buffer) WriteInt(w io.Writer, val int) error {
 	if val > math.MaxInt32 || val < math.MinInt32 {
-		return errors.New("util/binary/Buffer.WriteInt(): value must be in int32 range")
+		return errIntRange
 	}
 	b := buf.borrow(4)
-	if val < 0 {
-		val = -val + math.MaxInt32
-	}
-	binary.BigEndian.PutUint32(b, uint32(val))
+	buf.byteOrder().PutUint32(b, encodeInt(val))
 	_, err := w.Write(b)
 	return err
 }
@@ -169,47 +322,31 @@ func (buf *Buffer) ReadInt(r io.Reader) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	val := binary.BigEndian.Uint32(b)
-	if val <= math.MaxInt32 {
-		return int(val), nil
-	}
-	v := val - math.MaxInt32
-	return -int(v), nil
+	return decodeInt(buf.byteOrder().Uint32(b)), nil
 }
 
 // WriteInts writes zero or more int values to w.
 func (buf *Buffer) WriteInts(w io.Writer, val ...int) error {
-	if err := buf.WriteInt(w, len(val)); err != nil {
-		return err
-	}
 	for _, v := range val {
-		if err := buf.WriteInt(w, v); err != nil {
-			return err
+		if v > math.MaxInt32 || v < math.MinInt32 {
+			return errIntRange
 		}
 	}
-	return nil
+	order := buf.byteOrder()
+	return writeValues(buf, w, val, 4, func(b []byte, v int) { order.PutUint32(b, encodeInt(v)) })
 }
 
 // ReadInts reads zero or more inte values from r, where r reads
 // from a source that has used WriteInts to write the inte values.
 func (buf *Buffer) ReadInts(r io.Reader) ([]int, error) {
-	n, err := buf.ReadInt(r)
-	if err != nil {
-		return nil, err
-	}
-	values := make([]int, n)
-	for i := 0; i < n; i++ {
-		if values[i], err = buf.ReadInt(r); err != nil {
-			return nil, err
-		}
-	}
-	return values, nil
+	order := buf.byteOrder()
+	return readValues(buf, r, 4, func(b []byte) int { return decodeInt(order.Uint32(b)) })
 }
 
 // WriteInt64 write an int64 value to w.
 func (buf *Buffer) WriteInt64(w io.Writer, val int64) error {
 	b := buf.borrow(8)
-	binary.BigEndian.PutUint64(b, uint64(val))
+	buf.byteOrder().PutUint64(b, uint64(val))
 	_, err := w.Write(b)
 	return err
 }
@@ -221,42 +358,26 @@ func (buf *Buffer) ReadInt64(r io.Reader) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return int64(binary.BigEndian.Uint64(b)), nil
+	return int64(buf.byteOrder().Uint64(b)), nil
 }
 
 // WriteInt64s writes zero or more int64 values to w.
 func (buf *Buffer) WriteInt64s(w io.Writer, val ...int64) error {
-	if err := buf.WriteInt(w, len(val)); err != nil {
-		return err
-	}
-	for _, v := range val {
-		if err := buf.WriteInt64(w, v); err != nil {
-			return err
-		}
-	}
-	return nil
+	order := buf.byteOrder()
+	return writeValues(buf, w, val, 8, func(b []byte, v int64) { order.PutUint64(b, uint64(v)) })
 }
 
 // ReadInt64s reads zero or more int64 values from r, where r reads
 // from a source that has used WriteInt64s to write the int64 values.
 func (buf *Buffer) ReadInt64s(r io.Reader) ([]int64, error) {
-	n, err := buf.ReadInt(r)
-	if err != nil {
-		return nil, err
-	}
-	values := make([]int64, n)
-	for i := 0; i < n; i++ {
-		if values[i], err = buf.ReadInt64(r); err != nil {
-			return nil, err
-		}
-	}
-	return values, nil
+	order := buf.byteOrder()
+	return readValues(buf, r, 8, func(b []byte) int64 { return int64(order.Uint64(b)) })
 }
 
 // WriteFloat64 write a float64 value to w.
 func (buf *Buffer) WriteFloat64(w io.Writer, val float64) error {
 	b := buf.borrow(8)
-	binary.BigEndian.PutUint64(b, math.Float64bits(val))
+	buf.byteOrder().PutUint64(b, math.Float64bits(val))
 	_, err := w.Write(b)
 	return err
 }
@@ -268,42 +389,26 @@ func (buf *Buffer) ReadFloat64(r io.Reader) (float64, error) {
 	if err != nil {
 		return 0, err
 	}
-	return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	return math.Float64frombits(buf.byteOrder().Uint64(b)), nil
 }
 
 // WriteFloat64s writes zero or more float64 values to w.
 func (buf *Buffer) WriteFloat64s(w io.Writer, val ...float64) error {
-	if err := buf.WriteInt(w, len(val)); err != nil {
-		return err
-	}
-	for _, v := range val {
-		if err := buf.WriteFloat64(w, v); err != nil {
-			return err
-		}
-	}
-	return nil
+	order := buf.byteOrder()
+	return writeValues(buf, w, val, 8, func(b []byte, v float64) { order.PutUint64(b, math.Float64bits(v)) })
 }
 
 // ReadFloat64s reads zero or more float64 values from r, where r reads
 // from a source that has used WriteFloat64s to write the float64 values.
 func (buf *Buffer) ReadFloat64s(r io.Reader) ([]float64, error) {
-	n, err := buf.ReadInt(r)
-	if err != nil {
-		return nil, err
-	}
-	values := make([]float64, n)
-	for i := 0; i < n; i++ {
-		if values[i], err = buf.ReadFloat64(r); err != nil {
-			return nil, err
-		}
-	}
-	return values, nil
+	order := buf.byteOrder()
+	return readValues(buf, r, 8, func(b []byte) float64 { return math.Float64frombits(order.Uint64(b)) })
 }
 
 // WriteString writes a string value to w.
 func (buf *Buffer) WriteString(w io.Writer, val string) error {
 	n := len(val)
-	if err := buf.WriteInt(w, n); err != nil {
+	if err := buf.WriteLen(w, n); err != nil {
 		return err
 	}
 	b := buf.borrow(n)
@@ -315,7 +420,19 @@ func (buf *Buffer) WriteString(w io.Writer, val string) error {
 // ReadString reads a string value from r, where r reads from a source
 // that has used WriteString to write a string value.
 func (buf *Buffer) ReadString(r io.Reader) (string, error) {
-	n, err := buf.ReadInt(r)
+	return buf.readString(r, buf.limits.MaxStringLen)
+}
+
+// ReadStringMax is like ReadString, but fails with ErrLengthExceeded
+// before reading a string longer than max bytes. A max of zero or less
+// means only the limits of the buffer apply.
+func (buf *Buffer) ReadStringMax(r io.Reader, max int) (string, error) {
+	return buf.readString(r, minLimit(max, buf.limits.MaxStringLen))
+}
+
+// readString reads a string value of at most max bytes from r.
+func (buf *Buffer) readString(r io.Reader, max int) (string, error) {
+	n, err := buf.readLen(r, max, 1)
 	if err != nil {
 		return "", err
 	}
@@ -325,31 +442,14 @@ func (buf *Buffer) ReadString(r io.Reader) (string, error) {
 
 // WriteStrings writes zero or more string values to w.
 func (buf *Buffer) WriteStrings(w io.Writer, val ...string) error {
-	if err := buf.WriteInt(w, len(val)); err != nil {
-		return err
-	}
-	for _, v := range val {
-		if err := buf.WriteString(w, v); err != nil {
-			return err
-		}
-	}
-	return nil
+	return WriteSlice(buf, w, val, (*Buffer).WriteString)
 }
 
 // ReadStrings reads zero or more string values from r, where r reads
 // from a source that has used WriteStrings to write the string values.
 func (buf *Buffer) ReadStrings(r io.Reader) ([]string, error) {
-	n, err := buf.ReadInt(r)
-	if err != nil {
-		return nil, err
-	}
-	values := make([]string, n)
-	for i := 0; i < n; i++ {
-		if values[i], err = buf.ReadString(r); err != nil {
-			return nil, err
-		}
-	}
-	return values, nil
+	// Each string takes at least its length prefix.
+	return readSlice(buf, r, buf.lenSize(), (*Buffer).ReadString)
 }
 
 // Serializable describes an object that can serialize itself into a byte slice
@@ -362,7 +462,13 @@ type Serializable interface {
 // WriteSerializable writes a serializable object to w. If val implements
 // SerializableToBufRW, WriteSerializableBufRW will be called instead, passing in
 // buf as the buffer.
+//
+// If the type of val is registered with the registry of the buffer, val is
+// written in a versioned envelope instead. See Registry.
 func (buf *Buffer) WriteSerializable(w io.Writer, val Serializable) error {
+	if t := buf.registry.lookup(val); t != nil {
+		return buf.writeVersioned(w, t, val)
+	}
 	if s, ok := val.(SerializableToBufRW); ok {
 		return buf.WriteSerializableBufRW(w, s)
 	}
@@ -377,7 +483,13 @@ func (buf *Buffer) WriteSerializable(w io.Writer, val Serializable) error {
 // produced by WriteSerializable. If val implements SerializableToBufRW,
 // ReadSerializableBufRW will be called instead, passing in buf as the buffer.
 // buf as the buffer.
+//
+// If the type of val is registered with the registry of the buffer, a
+// versioned envelope is read, decoding older versions of the type into val.
 func (buf *Buffer) ReadSerializable(r io.Reader, val Serializable) error {
+	if t := buf.registry.lookup(val); t != nil {
+		return buf.readVersioned(r, t, val)
+	}
 	if s, ok := val.(SerializableToBufRW); ok {
 		return buf.ReadSerializableBufRW(r, s)
 	}
@@ -403,5 +515,9 @@ func (buf *Buffer) WriteSerializableBufRW(w io.Writer, val SerializableToBufRW)
 // ReadSerializableBufRW reads a serializable value from r, where r reads from a source
 // that has used ReadSerializableBufRW to write a serialized value of val.
 func (buf *Buffer) ReadSerializableBufRW(r io.Reader, val SerializableToBufRW) error {
+	if err := buf.enter(); err != nil {
+		return err
+	}
+	defer buf.leave()
 	return val.DeserializeFromBufRW(r, buf)
 }
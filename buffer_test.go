@@ -2,6 +2,8 @@ package bufrw
 
 import (
 	"bytes"
+	"encoding/binary"
+	"io"
 	"math"
 	"math/rand"
 	"reflect"
@@ -422,3 +424,339 @@ func TestBufferReadWriteStrings(t *testing.T) {
 		}
 	}
 }
+
+func TestNewBufferGrows(t *testing.T) {
+	buf := NewBuffer(8)
+	if got := len(buf.borrow(100)); got != 100 {
+		t.Fatalf("borrow(100) len = %d", got)
+	}
+	if got := len(buf.b); got != 100 {
+		t.Errorf("internal len after borrow(100) = %d, want 100", got)
+	}
+}
+
+func TestBufferBorrowMaxSize(t *testing.T) {
+	buf := NewBufferSize(4, 16)
+	if got := len(buf.borrow(8)); got != 8 {
+		t.Fatalf("borrow(8) len = %d", got)
+	}
+	if got := len(buf.b); got != 8 {
+		t.Errorf("internal len after borrow(8) = %d, want 8", got)
+	}
+	if got := len(buf.borrow(100)); got != 100 {
+		t.Fatalf("borrow(100) len = %d", got)
+	}
+	if got := len(buf.b); got != 16 {
+		t.Errorf("internal len after borrow(100) = %d, want 16", got)
+	}
+	buf.Shrink()
+	if got := len(buf.b); got != 4 {
+		t.Errorf("internal len after Shrink = %d, want 4", got)
+	}
+	buf.Reset()
+	if buf.b != nil {
+		t.Errorf("internal buffer after Reset = %v, want nil", buf.b)
+	}
+
+	var w bytes.Buffer
+	value := string(make([]byte, 1000))
+	if err := buf.WriteString(&w, value); err != nil {
+		t.Fatal(err)
+	}
+	got, err := buf.ReadString(&w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != value {
+		t.Errorf("Write/Read string of length %d = length %d", len(value), len(got))
+	}
+	if got := len(buf.b); got > 16 {
+		t.Errorf("internal len after large string = %d, want <= 16", got)
+	}
+}
+
+func TestBufferByteOrder(t *testing.T) {
+	type pair struct {
+		name  string
+		write func(buf *Buffer, w io.Writer) error
+		read  func(buf *Buffer, r io.Reader) (interface{}, error)
+		want  interface{}
+	}
+	pairs := []pair{
+		{"Bool", func(buf *Buffer, w io.Writer) error { return buf.WriteBool(w, true) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadBool(r) }, true},
+		{"Bools", func(buf *Buffer, w io.Writer) error { return buf.WriteBools(w, true, false) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadBools(r) }, []bool{true, false}},
+		{"ByteValue", func(buf *Buffer, w io.Writer) error { return buf.WriteByteValue(w, 7) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadByteValue(r) }, byte(7)},
+		{"ByteValues", func(buf *Buffer, w io.Writer) error { return buf.WriteByteValues(w, 1, 2) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadByteValues(r) }, []byte{1, 2}},
+		{"Int", func(buf *Buffer, w io.Writer) error { return buf.WriteInt(w, -12345) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadInt(r) }, -12345},
+		{"Ints", func(buf *Buffer, w io.Writer) error { return buf.WriteInts(w, -1, 1<<20) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadInts(r) }, []int{-1, 1 << 20}},
+		{"Int64", func(buf *Buffer, w io.Writer) error { return buf.WriteInt64(w, math.MinInt64+3) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadInt64(r) }, int64(math.MinInt64 + 3)},
+		{"Int64s", func(buf *Buffer, w io.Writer) error { return buf.WriteInt64s(w, -1, 1<<40) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadInt64s(r) }, []int64{-1, 1 << 40}},
+		{"Float64", func(buf *Buffer, w io.Writer) error { return buf.WriteFloat64(w, -1.5) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadFloat64(r) }, -1.5},
+		{"Float64s", func(buf *Buffer, w io.Writer) error { return buf.WriteFloat64s(w, 0.25, 1e100) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadFloat64s(r) }, []float64{0.25, 1e100}},
+		{"String", func(buf *Buffer, w io.Writer) error { return buf.WriteString(w, "abc") }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadString(r) }, "abc"},
+		{"Strings", func(buf *Buffer, w io.Writer) error { return buf.WriteStrings(w, "a", "") }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadStrings(r) }, []string{"a", ""}},
+		{"Int8", func(buf *Buffer, w io.Writer) error { return buf.WriteInt8(w, -3) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadInt8(r) }, int8(-3)},
+		{"Int8s", func(buf *Buffer, w io.Writer) error { return buf.WriteInt8s(w, -3, 3) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadInt8s(r) }, []int8{-3, 3}},
+		{"Int16", func(buf *Buffer, w io.Writer) error { return buf.WriteInt16(w, -300) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadInt16(r) }, int16(-300)},
+		{"Int16s", func(buf *Buffer, w io.Writer) error { return buf.WriteInt16s(w, -300, 300) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadInt16s(r) }, []int16{-300, 300}},
+		{"Int32", func(buf *Buffer, w io.Writer) error { return buf.WriteInt32(w, -70000) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadInt32(r) }, int32(-70000)},
+		{"Int32s", func(buf *Buffer, w io.Writer) error { return buf.WriteInt32s(w, -70000, 70000) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadInt32s(r) }, []int32{-70000, 70000}},
+		{"Uint8", func(buf *Buffer, w io.Writer) error { return buf.WriteUint8(w, 200) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadUint8(r) }, uint8(200)},
+		{"Uint8s", func(buf *Buffer, w io.Writer) error { return buf.WriteUint8s(w, 200, 1) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadUint8s(r) }, []uint8{200, 1}},
+		{"Uint16", func(buf *Buffer, w io.Writer) error { return buf.WriteUint16(w, 0x1234) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadUint16(r) }, uint16(0x1234)},
+		{"Uint16s", func(buf *Buffer, w io.Writer) error { return buf.WriteUint16s(w, 0x1234, 1) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadUint16s(r) }, []uint16{0x1234, 1}},
+		{"Uint32", func(buf *Buffer, w io.Writer) error { return buf.WriteUint32(w, 0x12345678) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadUint32(r) }, uint32(0x12345678)},
+		{"Uint32s", func(buf *Buffer, w io.Writer) error { return buf.WriteUint32s(w, 0x12345678, 1) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadUint32s(r) }, []uint32{0x12345678, 1}},
+		{"Uint64", func(buf *Buffer, w io.Writer) error { return buf.WriteUint64(w, math.MaxUint64-1) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadUint64(r) }, uint64(math.MaxUint64 - 1)},
+		{"Uint64s", func(buf *Buffer, w io.Writer) error { return buf.WriteUint64s(w, math.MaxUint64-1, 1) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadUint64s(r) }, []uint64{math.MaxUint64 - 1, 1}},
+		{"Uvarint", func(buf *Buffer, w io.Writer) error { return buf.WriteUvarint(w, 300) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadUvarint(r) }, uint64(300)},
+		{"Varint", func(buf *Buffer, w io.Writer) error { return buf.WriteVarint(w, -300) }, func(buf *Buffer, r io.Reader) (interface{}, error) { return buf.ReadVarint(r) }, int64(-300)},
+	}
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		for _, p := range pairs {
+			var buf Buffer
+			buf.SetByteOrder(order)
+			var w bytes.Buffer
+			if err := p.write(&buf, &w); err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.read(&buf, &w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, p.want) {
+				t.Errorf("%v %s: Write/Read %v = %v", order, p.name, p.want, got)
+			}
+			if w.Len() != 0 {
+				t.Errorf("%v %s: %d unread bytes", order, p.name, w.Len())
+			}
+		}
+	}
+
+	var buf Buffer
+	buf.SetByteOrder(binary.LittleEndian)
+	var w bytes.Buffer
+	if err := buf.WriteInt64(&w, 0x0102030405060708); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{8, 7, 6, 5, 4, 3, 2, 1}; !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("little-endian WriteInt64 = %x, want %x", w.Bytes(), want)
+	}
+}
+
+func TestBufferReadByteValuesInto(t *testing.T) {
+	values := []byte{1, 2, 3, 4}
+	var buf Buffer
+	var w bytes.Buffer
+	for i := 0; i < 3; i++ {
+		if err := buf.WriteByteValues(&w, values[:i+2]...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := make([]byte, 0, 3)
+	got, err := buf.ReadByteValuesInto(&w, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, values[:2]) || &got[0] != &dst[:1][0] {
+		t.Errorf("ReadByteValuesInto = %v, want %v in dst", got, values[:2])
+	}
+	got, err = buf.ReadByteValuesBorrowed(&w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, values[:3]) {
+		t.Errorf("ReadByteValuesBorrowed = %v, want %v", got, values[:3])
+	}
+	got, err = buf.ReadByteValuesInto(&w, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, values) {
+		t.Errorf("ReadByteValuesInto = %v, want %v", got, values)
+	}
+}
+
+// unbufferedReader hides any optimized interfaces of the wrapped reader,
+// so that each call to Read reaches it like a syscall would.
+type unbufferedReader struct {
+	r io.Reader
+}
+
+func (r *unbufferedReader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func benchmarkReadByteValues(b *testing.B, read func(buf *Buffer, r io.Reader) ([]byte, error)) {
+	var buf Buffer
+	var w bytes.Buffer
+	if err := buf.WriteByteValues(&w, make([]byte, 64*1024)...); err != nil {
+		b.Fatal(err)
+	}
+	data := w.Bytes()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := &unbufferedReader{r: bytes.NewReader(data)}
+		if _, err := read(&buf, r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadByteValues(b *testing.B) {
+	benchmarkReadByteValues(b, (*Buffer).ReadByteValues)
+}
+
+func BenchmarkReadByteValuesInto(b *testing.B) {
+	dst := make([]byte, 64*1024)
+	benchmarkReadByteValues(b, func(buf *Buffer, r io.Reader) ([]byte, error) {
+		return buf.ReadByteValuesInto(r, dst)
+	})
+}
+
+func BenchmarkReadByteValuesBorrowed(b *testing.B) {
+	benchmarkReadByteValues(b, (*Buffer).ReadByteValuesBorrowed)
+}
+
+// BenchmarkReadByteValuesPerByte measures the former implementation of
+// ReadByteValues, which read one byte at a time.
+func BenchmarkReadByteValuesPerByte(b *testing.B) {
+	benchmarkReadByteValues(b, func(buf *Buffer, r io.Reader) ([]byte, error) {
+		n, err := buf.ReadInt(r)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]byte, n)
+		for i := 0; i < n; i++ {
+			if values[i], err = buf.ReadByteValue(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	})
+}
+
+func TestBufferPluralWireFormat(t *testing.T) {
+	n := 3*chunkSize + 5
+	ints := make([]int, n)
+	int64s := make([]int64, n)
+	float64s := make([]float64, n)
+	bools := make([]bool, n)
+	for i := 0; i < n; i++ {
+		ints[i] = int(rand.Int31()) - math.MaxInt32/2
+		int64s[i] = rand.Int63()
+		float64s[i] = rand.NormFloat64()
+		bools[i] = rand.Int()%2 == 0
+	}
+	for _, buf := range []*Buffer{{}, NewBufferSize(4, 20)} {
+		var got, want bytes.Buffer
+		if err := buf.WriteInts(&got, ints...); err != nil {
+			t.Fatal(err)
+		}
+		if err := buf.WriteInt64s(&got, int64s...); err != nil {
+			t.Fatal(err)
+		}
+		if err := buf.WriteFloat64s(&got, float64s...); err != nil {
+			t.Fatal(err)
+		}
+		if err := buf.WriteBools(&got, bools...); err != nil {
+			t.Fatal(err)
+		}
+
+		var single Buffer
+		single.WriteInt(&want, n)
+		for _, v := range ints {
+			single.WriteInt(&want, v)
+		}
+		single.WriteInt(&want, n)
+		for _, v := range int64s {
+			single.WriteInt64(&want, v)
+		}
+		single.WriteInt(&want, n)
+		for _, v := range float64s {
+			single.WriteFloat64(&want, v)
+		}
+		single.WriteInt(&want, n)
+		for _, v := range bools {
+			single.WriteBool(&want, v)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Fatalf("plural encoding differs from encoding values one by one")
+		}
+
+		if values, err := buf.ReadInts(&got); err != nil || !reflect.DeepEqual(values, ints) {
+			t.Errorf("ReadInts: %v", err)
+		}
+		if values, err := buf.ReadInt64s(&got); err != nil || !reflect.DeepEqual(values, int64s) {
+			t.Errorf("ReadInt64s: %v", err)
+		}
+		if values, err := buf.ReadFloat64s(&got); err != nil || !reflect.DeepEqual(values, float64s) {
+			t.Errorf("ReadFloat64s: %v", err)
+		}
+		if values, err := buf.ReadBools(&got); err != nil || !reflect.DeepEqual(values, bools) {
+			t.Errorf("ReadBools: %v", err)
+		}
+	}
+}
+
+func TestBufferWriteIntsRange(t *testing.T) {
+	var buf Buffer
+	var w bytes.Buffer
+	if err := buf.WriteInts(&w, 1, math.MaxInt32+1); err == nil {
+		t.Error("WriteInts with value out of range: expected error")
+	}
+	if w.Len() != 0 {
+		t.Errorf("WriteInts with value out of range wrote %d bytes", w.Len())
+	}
+}
+
+// unbufferedWriter discards what is written to it, hiding any optimized
+// interfaces like a syscall would.
+type unbufferedWriter struct{}
+
+func (unbufferedWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func benchmarkPlural[T any](b *testing.B, values []T, size int, write func(*Buffer, io.Writer, ...T) error, read func(*Buffer, io.Reader) ([]T, error)) {
+	var buf Buffer
+	var w bytes.Buffer
+	if err := write(&buf, &w, values...); err != nil {
+		b.Fatal(err)
+	}
+	data := w.Bytes()
+	b.Run("Write", func(b *testing.B) {
+		b.SetBytes(int64(len(values) * size))
+		for i := 0; i < b.N; i++ {
+			if err := write(&buf, unbufferedWriter{}, values...); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Read", func(b *testing.B) {
+		b.SetBytes(int64(len(values) * size))
+		for i := 0; i < b.N; i++ {
+			if _, err := read(&buf, &unbufferedReader{r: bytes.NewReader(data)}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkBools(b *testing.B) {
+	benchmarkPlural(b, make([]bool, 64*1024), 1, (*Buffer).WriteBools, (*Buffer).ReadBools)
+}
+
+func BenchmarkInts(b *testing.B) {
+	benchmarkPlural(b, make([]int, 64*1024), 4, (*Buffer).WriteInts, (*Buffer).ReadInts)
+}
+
+func BenchmarkInt64s(b *testing.B) {
+	benchmarkPlural(b, make([]int64, 64*1024), 8, (*Buffer).WriteInt64s, (*Buffer).ReadInt64s)
+}
+
+func BenchmarkFloat64s(b *testing.B) {
+	benchmarkPlural(b, make([]float64, 64*1024), 8, (*Buffer).WriteFloat64s, (*Buffer).ReadFloat64s)
+}
@@ -0,0 +1,795 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snechholt/bufrw/internal/tag"
+)
+
+const (
+	bufrwPath       = "github.com/snechholt/bufrw"
+	generatedHeader = "// Code generated by bufrwgen. DO NOT EDIT."
+	annotation      = "//bufrw:generate"
+)
+
+// pkgInfo describes the package types are generated for.
+type pkgInfo struct {
+	types *types.Package
+
+	// annotated holds the names of the types annotated with
+	// //bufrw:generate, in source order.
+	annotated []string
+}
+
+// loadPackage parses and type-checks the package in dir, ignoring test
+// files and files previously generated by bufrwgen.
+func loadPackage(dir string) (*pkgInfo, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if isGenerated(f) {
+			continue
+		}
+		files = append(files, f)
+	}
+
+	// Errors are ignored, since the package may refer to the methods that
+	// are about to be generated.
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(error) {},
+	}
+	pkg, _ := conf.Check(bp.ImportPath, fset, files, nil)
+
+	info := &pkgInfo{types: pkg}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if isAnnotated(doc) {
+					info.annotated = append(info.annotated, ts.Name.Name)
+				}
+			}
+		}
+	}
+	return info, nil
+}
+
+func isGenerated(f *ast.File) bool {
+	for _, c := range f.Comments {
+		if c.Pos() > f.Package {
+			break
+		}
+		for _, line := range c.List {
+			if line.Text == generatedHeader {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isAnnotated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+// generate returns the formatted source of the methods of the named types,
+// or of the annotated types if names is empty.
+func generate(pkg *pkgInfo, names []string) ([]byte, error) {
+	if len(names) == 0 {
+		names = pkg.annotated
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no types to generate; annotate types with " + annotation + " or use -type")
+	}
+	g := &generator{
+		pkg:        pkg.types,
+		imports:    map[string]string{"io": "io", bufrwPath: "bufrw"},
+		generating: make(map[*types.TypeName]bool),
+	}
+	var named []*types.Named
+	for _, name := range names {
+		obj, ok := pkg.types.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("type %s not found", name)
+		}
+		n, ok := obj.Type().(*types.Named)
+		if !ok || n.TypeParams() != nil {
+			return nil, fmt.Errorf("type %s is not a non-generic defined type", name)
+		}
+		if _, ok := n.Underlying().(*types.Struct); !ok {
+			return nil, fmt.Errorf("type %s is not a struct type", name)
+		}
+		g.generating[obj] = true
+		named = append(named, n)
+	}
+	for _, n := range named {
+		if err := g.genType(n); err != nil {
+			return nil, fmt.Errorf("type %s: %w", n.Obj().Name(), err)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%s\n\npackage %s\n\nimport (\n", generatedHeader, pkg.types.Name())
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if isStd(path) {
+			fmt.Fprintf(&out, "%q\n", path)
+		}
+	}
+	out.WriteString("\n")
+	for _, path := range paths {
+		if !isStd(path) {
+			fmt.Fprintf(&out, "%q\n", path)
+		}
+	}
+	out.WriteString(")\n\n")
+	out.Write(g.body.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, out.Bytes())
+	}
+	return src, nil
+}
+
+// isStd reports whether path is the import path of a standard library
+// package.
+func isStd(path string) bool {
+	return !strings.Contains(strings.Split(path, "/")[0], ".")
+}
+
+type generator struct {
+	pkg        *types.Package
+	body       bytes.Buffer
+	imports    map[string]string
+	generating map[*types.TypeName]bool
+
+	// inlining holds the struct types whose fields are being inlined, to
+	// detect recursive types.
+	inlining []*types.Named
+
+	// n numbers the variables of the generated code.
+	n int
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+func (g *generator) use(path, name string) {
+	g.imports[path] = name
+}
+
+// typeString returns the Go syntax of t in the generated file.
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+		g.use(p.Path(), p.Name())
+		return p.Name()
+	})
+}
+
+// name returns a new variable name with the given prefix.
+func (g *generator) name(prefix string) string {
+	g.n++
+	return prefix + strconv.Itoa(g.n)
+}
+
+// addr returns an expression taking the address of expr.
+func addr(expr string) string {
+	if strings.HasPrefix(expr, "(*") && strings.HasSuffix(expr, ")") {
+		return expr[2 : len(expr)-1]
+	}
+	return "&" + expr
+}
+
+// convert returns an expression converting expr of type from to the type
+// named to, omitting the conversion if the types are identical.
+func (g *generator) convert(to string, expr string, from types.Type) string {
+	if b, ok := from.(*types.Basic); ok && b.Name() == to {
+		return expr
+	}
+	return to + "(" + expr + ")"
+}
+
+// zero returns the zero value of t.
+func (g *generator) zero(t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		}
+		return "0"
+	case *types.Pointer, *types.Slice, *types.Map:
+		return "nil"
+	}
+	return g.typeString(t) + "{}"
+}
+
+// check prints a statement returning the error of call if it fails.
+func (g *generator) check(call string, args ...any) {
+	g.printf("if err := "+call+"; err != nil {\nreturn err\n}\n", args...)
+}
+
+func (g *generator) genType(n *types.Named) error {
+	name := n.Obj().Name()
+	st := n.Underlying().(*types.Struct)
+
+	g.printf("// SerializeToBufRW implements bufrw.SerializableToBufRW.\n")
+	g.printf("func (v *%s) SerializeToBufRW(w io.Writer, buf *bufrw.Buffer) error {\n", name)
+	g.n = 0
+	if err := g.encodeFields("v", st); err != nil {
+		return err
+	}
+	g.printf("return nil\n}\n\n")
+
+	g.printf("// DeserializeFromBufRW implements bufrw.SerializableToBufRW.\n")
+	g.printf("func (v *%s) DeserializeFromBufRW(r io.Reader, buf *bufrw.Buffer) error {\n", name)
+	g.n = 0
+	if err := g.decodeFields("v", st); err != nil {
+		return err
+	}
+	g.printf("return nil\n}\n\n")
+
+	methods := types.NewMethodSet(types.NewPointer(n))
+	if methods.Lookup(g.pkg, "Serialize") == nil && methods.Lookup(g.pkg, "Deserialize") == nil {
+		g.use("bytes", "bytes")
+		g.printf("// Serialize implements bufrw.Serializable.\n")
+		g.printf("func (v *%s) Serialize() ([]byte, error) {\n", name)
+		g.printf("var b bytes.Buffer\nvar buf bufrw.Buffer\n")
+		g.printf("err := v.SerializeToBufRW(&b, &buf)\nreturn b.Bytes(), err\n}\n\n")
+		g.printf("// Deserialize implements bufrw.Serializable.\n")
+		g.printf("func (v *%s) Deserialize(b []byte) error {\n", name)
+		g.printf("var buf bufrw.Buffer\nreturn v.DeserializeFromBufRW(bytes.NewReader(b), &buf)\n}\n\n")
+	}
+	return nil
+}
+
+// fields returns the exported fields of st and their options.
+func fields(st *types.Struct) ([]*types.Var, []tag.Options, error) {
+	var vars []*types.Var
+	var opts []tag.Options
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if !f.Exported() {
+			continue
+		}
+		o, err := tag.Parse(reflect.StructTag(st.Tag(i)).Get("bufrw"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %s: %w", f.Name(), err)
+		}
+		if o.Skip {
+			continue
+		}
+		vars = append(vars, f)
+		opts = append(opts, o)
+	}
+	return vars, opts, nil
+}
+
+func (g *generator) encodeFields(expr string, st *types.Struct) error {
+	vars, opts, err := fields(st)
+	if err != nil {
+		return err
+	}
+	for i, f := range vars {
+		if err := g.encode(expr+"."+f.Name(), f.Type(), opts[i]); err != nil {
+			return fmt.Errorf("field %s: %w", f.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (g *generator) decodeFields(target string, st *types.Struct) error {
+	vars, opts, err := fields(st)
+	if err != nil {
+		return err
+	}
+	for i, f := range vars {
+		if err := g.decode(target+"."+f.Name(), f.Type(), opts[i]); err != nil {
+			return fmt.Errorf("field %s: %w", f.Name(), err)
+		}
+	}
+	return nil
+}
+
+// isSerializable reports whether values of type t are encoded with
+// WriteSerializable, which is the case if t is being generated or *t
+// implements bufrw.Serializable.
+func (g *generator) isSerializable(t types.Type) bool {
+	if _, ok := t.Underlying().(*types.Pointer); ok {
+		return false
+	}
+	if n, ok := t.(*types.Named); ok && g.generating[n.Obj()] {
+		return true
+	}
+	methods := types.NewMethodSet(types.NewPointer(t))
+	return methods.Lookup(nil, "Serialize") != nil && methods.Lookup(nil, "Deserialize") != nil
+}
+
+// isTime reports whether t is time.Time, which is written with WriteTime.
+func isTime(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == "time" && n.Obj().Name() == "Time"
+}
+
+// isMarshaler reports whether t implements the binary or text marshaler
+// and unmarshaler interfaces of package encoding through a pointer
+// receiver, so that it is written with WriteMarshaler.
+func isMarshaler(t types.Type) bool {
+	if _, ok := t.Underlying().(*types.Pointer); ok {
+		return false
+	}
+	methods := types.NewMethodSet(types.NewPointer(t))
+	has := func(name string) bool { return methods.Lookup(nil, name) != nil }
+	return (has("MarshalBinary") && has("UnmarshalBinary")) || (has("MarshalText") && has("UnmarshalText"))
+}
+
+// enterStruct records that the fields of t are being inlined, failing if
+// t is a recursive type. The returned function must be called when done.
+func (g *generator) enterStruct(t types.Type) (func(), error) {
+	n, ok := t.(*types.Named)
+	if !ok {
+		return func() {}, nil
+	}
+	for _, m := range g.inlining {
+		if m == n {
+			return nil, fmt.Errorf("recursive type %s must be generated as well", g.typeString(n))
+		}
+	}
+	g.inlining = append(g.inlining, n)
+	return func() { g.inlining = g.inlining[:len(g.inlining)-1] }, nil
+}
+
+// nonZero returns an expression reporting whether expr of type t is not
+// the zero value.
+func (g *generator) nonZero(expr string, t types.Type) (string, error) {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return expr, nil
+		case u.Info()&types.IsString != 0:
+			return expr + ` != ""`, nil
+		case u.Info()&types.IsNumeric != 0:
+			return expr + " != 0", nil
+		}
+	case *types.Pointer, *types.Slice, *types.Map:
+		return expr + " != nil", nil
+	case *types.Struct, *types.Array:
+		if types.Comparable(t) {
+			return fmt.Sprintf("%s != (%s{})", expr, g.typeString(t)), nil
+		}
+	}
+	return "", fmt.Errorf("optional %s is not supported", g.typeString(t))
+}
+
+func errNotApplicable(t types.Type) error {
+	return fmt.Errorf("tag options do not apply to %s", t)
+}
+
+// checkMax prints a statement failing if the length n exceeds max.
+func (g *generator) checkMax(n string, max int) {
+	if max <= 0 {
+		return
+	}
+	g.use("fmt", "fmt")
+	g.printf("if %s > %d {\n", n, max)
+	g.printf("return fmt.Errorf(\"bufrw: length %%d exceeds %d: %%w\", %s, bufrw.ErrLengthExceeded)\n}\n", max, n)
+}
+
+// encode prints the statements writing expr of type t.
+func (g *generator) encode(expr string, t types.Type, opts tag.Options) error {
+	if opts.Optional {
+		nonZero, err := g.nonZero(expr, t)
+		if err != nil {
+			return err
+		}
+		g.check("buf.WriteBool(w, %s)", nonZero)
+		g.printf("if %s {\n", nonZero)
+		opts.Optional = false
+		if err := g.encode(expr, t, opts); err != nil {
+			return err
+		}
+		g.printf("}\n")
+		return nil
+	}
+	if opts == (tag.Options{}) && g.isSerializable(t) {
+		g.check("buf.WriteSerializable(w, %s)", addr(expr))
+		return nil
+	}
+	if isTime(t) {
+		if opts != (tag.Options{}) {
+			return errNotApplicable(t)
+		}
+		g.check("buf.WriteTime(w, %s)", expr)
+		return nil
+	}
+	if opts == (tag.Options{}) && isMarshaler(t) {
+		g.check("buf.WriteMarshaler(w, %s)", addr(expr))
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return g.encodeBasic(expr, t, u, opts)
+	case *types.Pointer:
+		g.check("buf.WriteBool(w, %s != nil)", expr)
+		g.printf("if %s != nil {\n", expr)
+		if err := g.encode("(*"+expr+")", u.Elem(), opts); err != nil {
+			return err
+		}
+		g.printf("}\n")
+	case *types.Slice:
+		g.checkMaxLen(expr, opts.Max)
+		switch {
+		case opts.Packed && types.Identical(u.Elem(), types.Typ[types.Bool]):
+			g.check("buf.WritePackedBools(w, %s...)", expr)
+		case opts.Width == "" && types.Identical(u.Elem(), types.Typ[types.Byte]):
+			g.check("buf.WriteByteValues(w, %s...)", expr)
+		default:
+			i := g.name("i")
+			g.check("buf.WriteNullableLen(w, len(%s), %s == nil)", expr, expr)
+			g.printf("for %s := range %s {\n", i, expr)
+			if err := g.encode(expr+"["+i+"]", u.Elem(), tag.Options{Width: opts.Width, Packed: opts.Packed}); err != nil {
+				return err
+			}
+			g.printf("}\n")
+		}
+	case *types.Array:
+		if opts.Max > 0 {
+			return errors.New("tag option max does not apply to arrays")
+		}
+		i := g.name("i")
+		g.printf("for %s := range %s {\n", i, expr)
+		if err := g.encode(expr+"["+i+"]", u.Elem(), opts); err != nil {
+			return err
+		}
+		g.printf("}\n")
+	case *types.Map:
+		return g.encodeMap(expr, u, opts)
+	case *types.Struct:
+		if opts.Width != "" || opts.Packed || opts.Max > 0 {
+			return errNotApplicable(t)
+		}
+		leave, err := g.enterStruct(t)
+		if err != nil {
+			return err
+		}
+		defer leave()
+		return g.encodeFields(expr, u)
+	default:
+		return fmt.Errorf("unsupported type %s", g.typeString(t))
+	}
+	return nil
+}
+
+// checkMaxLen prints a statement failing if the length of expr exceeds max.
+func (g *generator) checkMaxLen(expr string, max int) {
+	g.checkMax("len("+expr+")", max)
+}
+
+func (g *generator) encodeMap(expr string, t *types.Map, opts tag.Options) error {
+	g.checkMaxLen(expr, opts.Max)
+	g.check("buf.WriteNullableLen(w, len(%s), %s == nil)", expr, expr)
+	k := g.name("k")
+	less := keyLess(t.Key())
+	if less != "" {
+		keys := g.name("keys")
+		g.use("sort", "sort")
+		g.printf("%s := make([]%s, 0, len(%s))\n", keys, g.typeString(t.Key()), expr)
+		g.printf("for %s := range %s {\n%s = append(%s, %s)\n}\n", k, expr, keys, keys, k)
+		g.printf("sort.Slice(%s, func(i, j int) bool { return %s })\n", keys,
+			fmt.Sprintf(less, keys+"[i]", keys+"[j]"))
+		g.printf("for _, %s := range %s {\n", k, keys)
+	} else {
+		g.printf("for %s := range %s {\n", k, expr)
+	}
+	if err := g.encode(k, t.Key(), tag.Options{Width: opts.Width}); err != nil {
+		return err
+	}
+	e := g.name("e")
+	g.printf("%s := %s[%s]\n", e, expr, k)
+	if err := g.encode(e, t.Elem(), tag.Options{Width: opts.Width, Packed: opts.Packed}); err != nil {
+		return err
+	}
+	g.printf("}\n")
+	return nil
+}
+
+// keyLess returns a format string comparing two map keys of type t, or ""
+// if keys of type t are not ordered.
+func keyLess(t types.Type) string {
+	u, ok := t.Underlying().(*types.Basic)
+	switch {
+	case !ok:
+		return ""
+	case u.Info()&types.IsBoolean != 0:
+		return "!%s && %s"
+	case u.Info()&(types.IsInteger|types.IsFloat|types.IsString) != 0:
+		return "%s < %s"
+	}
+	return ""
+}
+
+// basicMethod returns the suffix of the Buffer methods encoding values of
+// kind k with the given width option, and the Go type of their values.
+func basicMethod(k types.BasicKind, width string) (string, string, error) {
+	switch k {
+	case types.Int, types.Int8, types.Int16, types.Int32, types.Int64:
+		switch width {
+		case tag.Varint:
+			return "Varint", "int64", nil
+		case tag.Fixed32:
+			return "Int", "int", nil
+		}
+	case types.Uint, types.Uint8, types.Uint16, types.Uint32, types.Uint64, types.Uintptr:
+		switch width {
+		case tag.Varint:
+			return "Uvarint", "uint64", nil
+		case tag.Fixed32:
+			return "Uint32", "uint32", nil
+		}
+	}
+	if width != "" {
+		return "", "", fmt.Errorf("tag option %s does not apply to %s", width, types.Typ[k])
+	}
+	switch k {
+	case types.Bool:
+		return "Bool", "bool", nil
+	case types.Int8:
+		return "Int8", "int8", nil
+	case types.Int16:
+		return "Int16", "int16", nil
+	case types.Int32:
+		return "Int32", "int32", nil
+	case types.Int, types.Int64:
+		return "Int64", "int64", nil
+	case types.Uint8:
+		return "Uint8", "uint8", nil
+	case types.Uint16:
+		return "Uint16", "uint16", nil
+	case types.Uint32:
+		return "Uint32", "uint32", nil
+	case types.Uint, types.Uint64, types.Uintptr:
+		return "Uint64", "uint64", nil
+	case types.Float32:
+		return "Float32", "float32", nil
+	case types.Float64:
+		return "Float64", "float64", nil
+	case types.Complex64:
+		return "Complex64", "complex64", nil
+	case types.Complex128:
+		return "Complex128", "complex128", nil
+	case types.String:
+		return "String", "string", nil
+	}
+	return "", "", fmt.Errorf("unsupported type %s", types.Typ[k])
+}
+
+func (g *generator) encodeBasic(expr string, t types.Type, u *types.Basic, opts tag.Options) error {
+	if opts.Packed || (opts.Max > 0 && u.Kind() != types.String) {
+		return errNotApplicable(t)
+	}
+	method, typ, err := basicMethod(u.Kind(), opts.Width)
+	if err != nil {
+		return err
+	}
+	switch {
+	case method == "Uint32" && opts.Width == tag.Fixed32 && basicBits(u.Kind()) > 32:
+		g.use("fmt", "fmt")
+		g.use("math", "math")
+		g.printf("if uint64(%s) > math.MaxUint32 {\n", expr)
+		g.printf("return fmt.Errorf(\"bufrw: value %%d overflows uint32\", %s)\n}\n", expr)
+		g.check("buf.WriteUint32(w, uint32(%s))", expr)
+	default:
+		g.checkMaxLen(expr, opts.Max)
+		g.check("buf.Write%s(w, %s)", method, g.convert(typ, expr, t))
+	}
+	return nil
+}
+
+// basicBits returns the size in bits of integers of kind k.
+func basicBits(k types.BasicKind) int {
+	switch k {
+	case types.Int8, types.Uint8:
+		return 8
+	case types.Int16, types.Uint16:
+		return 16
+	case types.Int32, types.Uint32:
+		return 32
+	}
+	return 64
+}
+
+// decode prints the statements reading a value of type t into target.
+func (g *generator) decode(target string, t types.Type, opts tag.Options) error {
+	if opts.Optional {
+		ok := g.name("ok")
+		g.printf("{\n%s, err := buf.ReadBool(r)\nif err != nil {\nreturn err\n}\n", ok)
+		g.printf("if %s {\n", ok)
+		opts.Optional = false
+		if err := g.decode(target, t, opts); err != nil {
+			return err
+		}
+		g.printf("} else {\n%s = %s\n}\n}\n", target, g.zero(t))
+		return nil
+	}
+	if opts == (tag.Options{}) && g.isSerializable(t) {
+		g.check("buf.ReadSerializable(r, %s)", addr(target))
+		return nil
+	}
+	if isTime(t) {
+		if opts != (tag.Options{}) {
+			return errNotApplicable(t)
+		}
+		val := g.name("val")
+		g.printf("{\n%s, err := buf.ReadTime(r)\nif err != nil {\nreturn err\n}\n%s = %s\n}\n", val, target, val)
+		return nil
+	}
+	if opts == (tag.Options{}) && isMarshaler(t) {
+		g.check("buf.ReadMarshaler(r, %s)", addr(target))
+		return nil
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		return g.decodeBasic(target, t, u, opts)
+	case *types.Pointer:
+		ok, p := g.name("ok"), g.name("p")
+		g.printf("{\n%s, err := buf.ReadBool(r)\nif err != nil {\nreturn err\n}\n", ok)
+		g.printf("if %s {\n%s := new(%s)\n", ok, p, g.typeString(u.Elem()))
+		if err := g.decode("(*"+p+")", u.Elem(), opts); err != nil {
+			return err
+		}
+		g.printf("%s = %s\n} else {\n%s = nil\n}\n}\n", target, p, target)
+	case *types.Slice:
+		val := g.name("val")
+		switch {
+		case opts.Packed && types.Identical(u.Elem(), types.Typ[types.Bool]):
+			if opts.Max > 0 {
+				g.printf("{\n%s, err := buf.ReadPackedBoolsMax(r, %d)\nif err != nil {\nreturn err\n}\n", val, opts.Max)
+			} else {
+				g.printf("{\n%s, err := buf.ReadPackedBools(r)\nif err != nil {\nreturn err\n}\n", val)
+			}
+			g.printf("%s = %s(%s)\n}\n", target, g.typeString(t), val)
+		case opts.Width == "" && types.Identical(u.Elem(), types.Typ[types.Byte]) && opts.Max <= 0:
+			g.printf("{\n%s, err := buf.ReadByteValues(r)\nif err != nil {\nreturn err\n}\n", val)
+			g.printf("%s = %s(%s)\n}\n", target, g.typeString(t), val)
+		default:
+			n, i := g.name("n"), g.name("i")
+			g.printf("{\n%s, err := buf.ReadNullableLen(r)\nif err != nil {\nreturn err\n}\n", n)
+			g.checkMax(n, opts.Max)
+			g.printf("var %s %s\nif %s >= 0 {\n%s = make(%s, %s)\n", val, g.typeString(t), n, val, g.typeString(t), n)
+			if opts.Width == "" && types.Identical(u.Elem(), types.Typ[types.Byte]) {
+				g.printf("if _, err := io.ReadFull(r, %s); err != nil {\nreturn err\n}\n", val)
+			} else {
+				g.printf("for %s := range %s {\n", i, val)
+				if err := g.decode(val+"["+i+"]", u.Elem(), tag.Options{Width: opts.Width, Packed: opts.Packed}); err != nil {
+					return err
+				}
+				g.printf("}\n")
+			}
+			g.printf("}\n%s = %s\n}\n", target, val)
+		}
+	case *types.Array:
+		if opts.Max > 0 {
+			return errors.New("tag option max does not apply to arrays")
+		}
+		i := g.name("i")
+		g.printf("for %s := range %s {\n", i, target)
+		if err := g.decode(target+"["+i+"]", u.Elem(), opts); err != nil {
+			return err
+		}
+		g.printf("}\n")
+	case *types.Map:
+		n, i, m, k, e := g.name("n"), g.name("i"), g.name("m"), g.name("k"), g.name("e")
+		g.printf("{\n%s, err := buf.ReadNullableLen(r)\nif err != nil {\nreturn err\n}\n", n)
+		g.checkMax(n, opts.Max)
+		g.printf("var %s %s\nif %s >= 0 {\n%s = make(%s, %s)\n}\n", m, g.typeString(t), n, m, g.typeString(t), n)
+		g.printf("for %s := 0; %s < %s; %s++ {\n", i, i, n, i)
+		g.printf("var %s %s\n", k, g.typeString(u.Key()))
+		if err := g.decode(k, u.Key(), tag.Options{Width: opts.Width}); err != nil {
+			return err
+		}
+		g.printf("var %s %s\n", e, g.typeString(u.Elem()))
+		if err := g.decode(e, u.Elem(), tag.Options{Width: opts.Width, Packed: opts.Packed}); err != nil {
+			return err
+		}
+		g.printf("%s[%s] = %s\n}\n%s = %s\n}\n", m, k, e, target, m)
+	case *types.Struct:
+		if opts.Width != "" || opts.Packed || opts.Max > 0 {
+			return errNotApplicable(t)
+		}
+		leave, err := g.enterStruct(t)
+		if err != nil {
+			return err
+		}
+		defer leave()
+		return g.decodeFields(target, u)
+	default:
+		return fmt.Errorf("unsupported type %s", g.typeString(t))
+	}
+	return nil
+}
+
+func (g *generator) decodeBasic(target string, t types.Type, u *types.Basic, opts tag.Options) error {
+	if opts.Packed || (opts.Max > 0 && u.Kind() != types.String) {
+		return errNotApplicable(t)
+	}
+	method, typ, err := basicMethod(u.Kind(), opts.Width)
+	if err != nil {
+		return err
+	}
+	val := g.name("val")
+	if opts.Max > 0 {
+		// Only strings take a max, which is checked before reading them.
+		g.printf("{\n%s, err := buf.Read%sMax(r, %d)\nif err != nil {\nreturn err\n}\n", val, method, opts.Max)
+	} else {
+		g.printf("{\n%s, err := buf.Read%s(r)\nif err != nil {\nreturn err\n}\n", val, method)
+	}
+	typeName := g.typeString(t)
+	switch {
+	case opts.Width != "" && basicBits(u.Kind()) < basicBits(basicKind(typ)):
+		g.use("fmt", "fmt")
+		g.printf("if %s(%s(%s)) != %s {\n", typ, typeName, val, val)
+		g.printf("return fmt.Errorf(\"bufrw: value %%d overflows %s\", %s)\n}\n", typeName, val)
+		g.printf("%s = %s(%s)\n", target, typeName, val)
+	case types.Identical(t, types.Universe.Lookup(typ).Type()):
+		g.printf("%s = %s\n", target, val)
+	default:
+		g.printf("%s = %s(%s)\n", target, typeName, val)
+	}
+	g.printf("}\n")
+	return nil
+}
+
+// basicKind returns the kind of the basic type with the given name.
+func basicKind(name string) types.BasicKind {
+	return types.Universe.Lookup(name).Type().(*types.Basic).Kind()
+}
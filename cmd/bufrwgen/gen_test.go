@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerateGolden(t *testing.T) {
+	dir := filepath.Join("internal", "example")
+	pkg, err := loadPackage(dir)
+	if err != nil {
+		t.Fatalf("loadPackage() error: %v", err)
+	}
+	if got, want := strings.Join(pkg.annotated, ","), "Message,Node"; got != want {
+		t.Errorf("Annotated types are %s, want %s", got, want)
+	}
+	got, err := generate(pkg, nil)
+	if err != nil {
+		t.Fatalf("generate() error: %v", err)
+	}
+	golden := filepath.Join(dir, "example_bufrw.go")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Generated code differs from %s; run go test -update to update it", golden)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"NoTypes", "type T struct{ A int }", "no types to generate"},
+		{"NotStruct", "//bufrw:generate\ntype T int", "not a struct type"},
+		{"Unsupported", "//bufrw:generate\ntype T struct{ C chan int }", "unsupported type"},
+		{"UnknownTag", "//bufrw:generate\ntype T struct{ A int `bufrw:\"bogus\"` }", "unknown tag option"},
+		{"NotApplicable", "//bufrw:generate\ntype T struct{ S string `bufrw:\"varint\"` }", "does not apply"},
+		{"Recursive", "//bufrw:generate\ntype T struct{ A *U }\ntype U struct{ B *U }", "recursive"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := "package p\n\n" + test.src + "\n"
+			if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			pkg, err := loadPackage(dir)
+			if err != nil {
+				t.Fatalf("loadPackage() error: %v", err)
+			}
+			_, err = generate(pkg, nil)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("generate() error is %v, want error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestGenerateType(t *testing.T) {
+	dir := t.TempDir()
+	src := "package p\n\ntype T struct{ A int }\n"
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(dir, []string{"T"}, ""); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "p_bufrw.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(got, []byte("func (v *T) SerializeToBufRW(")) {
+		t.Errorf("Generated code does not declare SerializeToBufRW:\n%s", got)
+	}
+}
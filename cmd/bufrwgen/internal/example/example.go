@@ -0,0 +1,79 @@
+// Package example holds types used to test the code generated by bufrwgen.
+package example
+
+import (
+	"math/big"
+	"net/url"
+	"time"
+)
+
+//go:generate go run github.com/snechholt/bufrw/cmd/bufrwgen
+
+// Kind is a named integer type.
+type Kind uint16
+
+// Point is a comparable struct that is not generated, so its fields are
+// inlined where it is used.
+type Point struct {
+	X, Y int32
+}
+
+// Message exercises the field types and tag options supported by bufrwgen.
+//
+//bufrw:generate
+type Message struct {
+	Bool       bool
+	Int        int
+	Int8       int8
+	Int16      int16
+	Int32      int32
+	Int64      int64
+	Uint       uint
+	Uint8      uint8
+	Uint16     uint16
+	Uint32     uint32
+	Uint64     uint64
+	Float32    float32
+	Float64    float64
+	Complex64  complex64
+	Complex128 complex128
+	String     string
+	Kind       Kind
+	Bytes      []byte
+	Strings    []string
+	Array      [3]int16
+	Map        map[string][]int64
+	Points     map[int]Point
+	Set        map[Kind]bool
+	Pointer    *Point
+	Nested     *Node
+	Nodes      []Node
+	Time       time.Time
+	Duration   time.Duration
+	Amount     *big.Int
+	Link       url.URL
+	ID         int64           `bufrw:"varint"`
+	Port       uint16          `bufrw:"varint"`
+	Legacy     int             `bufrw:"fixed32"`
+	Count      uint64          `bufrw:"fixed32"`
+	Flags      []bool          `bufrw:"packed,max=64"`
+	Name       string          `bufrw:"max=16"`
+	Tags       []string        `bufrw:"max=4"`
+	Raw        []byte          `bufrw:"max=64"`
+	Sizes      []int32         `bufrw:"varint"`
+	Counts     map[Kind]uint32 `bufrw:"varint,max=8"`
+	Note       *string         `bufrw:"optional"`
+	Score      int64           `bufrw:"optional,varint"`
+	Origin     Point           `bufrw:"optional"`
+	Deadline   time.Time       `bufrw:"optional"`
+	Internal   string          `bufrw:"-"`
+	unexported int
+}
+
+// Node is a recursive type, which must itself be generated.
+//
+//bufrw:generate
+type Node struct {
+	Name     string
+	Children []*Node
+}
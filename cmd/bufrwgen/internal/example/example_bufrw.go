@@ -0,0 +1,984 @@
+// Code generated by bufrwgen. DO NOT EDIT.
+
+package example
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/snechholt/bufrw"
+)
+
+// SerializeToBufRW implements bufrw.SerializableToBufRW.
+func (v *Message) SerializeToBufRW(w io.Writer, buf *bufrw.Buffer) error {
+	if err := buf.WriteBool(w, v.Bool); err != nil {
+		return err
+	}
+	if err := buf.WriteInt64(w, int64(v.Int)); err != nil {
+		return err
+	}
+	if err := buf.WriteInt8(w, v.Int8); err != nil {
+		return err
+	}
+	if err := buf.WriteInt16(w, v.Int16); err != nil {
+		return err
+	}
+	if err := buf.WriteInt32(w, v.Int32); err != nil {
+		return err
+	}
+	if err := buf.WriteInt64(w, v.Int64); err != nil {
+		return err
+	}
+	if err := buf.WriteUint64(w, uint64(v.Uint)); err != nil {
+		return err
+	}
+	if err := buf.WriteUint8(w, v.Uint8); err != nil {
+		return err
+	}
+	if err := buf.WriteUint16(w, v.Uint16); err != nil {
+		return err
+	}
+	if err := buf.WriteUint32(w, v.Uint32); err != nil {
+		return err
+	}
+	if err := buf.WriteUint64(w, v.Uint64); err != nil {
+		return err
+	}
+	if err := buf.WriteFloat32(w, v.Float32); err != nil {
+		return err
+	}
+	if err := buf.WriteFloat64(w, v.Float64); err != nil {
+		return err
+	}
+	if err := buf.WriteComplex64(w, v.Complex64); err != nil {
+		return err
+	}
+	if err := buf.WriteComplex128(w, v.Complex128); err != nil {
+		return err
+	}
+	if err := buf.WriteString(w, v.String); err != nil {
+		return err
+	}
+	if err := buf.WriteUint16(w, uint16(v.Kind)); err != nil {
+		return err
+	}
+	if err := buf.WriteByteValues(w, v.Bytes...); err != nil {
+		return err
+	}
+	if err := buf.WriteNullableLen(w, len(v.Strings), v.Strings == nil); err != nil {
+		return err
+	}
+	for i1 := range v.Strings {
+		if err := buf.WriteString(w, v.Strings[i1]); err != nil {
+			return err
+		}
+	}
+	for i2 := range v.Array {
+		if err := buf.WriteInt16(w, v.Array[i2]); err != nil {
+			return err
+		}
+	}
+	if err := buf.WriteNullableLen(w, len(v.Map), v.Map == nil); err != nil {
+		return err
+	}
+	keys4 := make([]string, 0, len(v.Map))
+	for k3 := range v.Map {
+		keys4 = append(keys4, k3)
+	}
+	sort.Slice(keys4, func(i, j int) bool { return keys4[i] < keys4[j] })
+	for _, k3 := range keys4 {
+		if err := buf.WriteString(w, k3); err != nil {
+			return err
+		}
+		e5 := v.Map[k3]
+		if err := buf.WriteNullableLen(w, len(e5), e5 == nil); err != nil {
+			return err
+		}
+		for i6 := range e5 {
+			if err := buf.WriteInt64(w, e5[i6]); err != nil {
+				return err
+			}
+		}
+	}
+	if err := buf.WriteNullableLen(w, len(v.Points), v.Points == nil); err != nil {
+		return err
+	}
+	keys8 := make([]int, 0, len(v.Points))
+	for k7 := range v.Points {
+		keys8 = append(keys8, k7)
+	}
+	sort.Slice(keys8, func(i, j int) bool { return keys8[i] < keys8[j] })
+	for _, k7 := range keys8 {
+		if err := buf.WriteInt64(w, int64(k7)); err != nil {
+			return err
+		}
+		e9 := v.Points[k7]
+		if err := buf.WriteInt32(w, e9.X); err != nil {
+			return err
+		}
+		if err := buf.WriteInt32(w, e9.Y); err != nil {
+			return err
+		}
+	}
+	if err := buf.WriteNullableLen(w, len(v.Set), v.Set == nil); err != nil {
+		return err
+	}
+	keys11 := make([]Kind, 0, len(v.Set))
+	for k10 := range v.Set {
+		keys11 = append(keys11, k10)
+	}
+	sort.Slice(keys11, func(i, j int) bool { return keys11[i] < keys11[j] })
+	for _, k10 := range keys11 {
+		if err := buf.WriteUint16(w, uint16(k10)); err != nil {
+			return err
+		}
+		e12 := v.Set[k10]
+		if err := buf.WriteBool(w, e12); err != nil {
+			return err
+		}
+	}
+	if err := buf.WriteBool(w, v.Pointer != nil); err != nil {
+		return err
+	}
+	if v.Pointer != nil {
+		if err := buf.WriteInt32(w, (*v.Pointer).X); err != nil {
+			return err
+		}
+		if err := buf.WriteInt32(w, (*v.Pointer).Y); err != nil {
+			return err
+		}
+	}
+	if err := buf.WriteBool(w, v.Nested != nil); err != nil {
+		return err
+	}
+	if v.Nested != nil {
+		if err := buf.WriteSerializable(w, v.Nested); err != nil {
+			return err
+		}
+	}
+	if err := buf.WriteNullableLen(w, len(v.Nodes), v.Nodes == nil); err != nil {
+		return err
+	}
+	for i13 := range v.Nodes {
+		if err := buf.WriteSerializable(w, &v.Nodes[i13]); err != nil {
+			return err
+		}
+	}
+	if err := buf.WriteTime(w, v.Time); err != nil {
+		return err
+	}
+	if err := buf.WriteInt64(w, int64(v.Duration)); err != nil {
+		return err
+	}
+	if err := buf.WriteBool(w, v.Amount != nil); err != nil {
+		return err
+	}
+	if v.Amount != nil {
+		if err := buf.WriteMarshaler(w, v.Amount); err != nil {
+			return err
+		}
+	}
+	if err := buf.WriteMarshaler(w, &v.Link); err != nil {
+		return err
+	}
+	if err := buf.WriteVarint(w, v.ID); err != nil {
+		return err
+	}
+	if err := buf.WriteUvarint(w, uint64(v.Port)); err != nil {
+		return err
+	}
+	if err := buf.WriteInt(w, v.Legacy); err != nil {
+		return err
+	}
+	if uint64(v.Count) > math.MaxUint32 {
+		return fmt.Errorf("bufrw: value %d overflows uint32", v.Count)
+	}
+	if err := buf.WriteUint32(w, uint32(v.Count)); err != nil {
+		return err
+	}
+	if len(v.Flags) > 64 {
+		return fmt.Errorf("bufrw: length %d exceeds 64: %w", len(v.Flags), bufrw.ErrLengthExceeded)
+	}
+	if err := buf.WritePackedBools(w, v.Flags...); err != nil {
+		return err
+	}
+	if len(v.Name) > 16 {
+		return fmt.Errorf("bufrw: length %d exceeds 16: %w", len(v.Name), bufrw.ErrLengthExceeded)
+	}
+	if err := buf.WriteString(w, v.Name); err != nil {
+		return err
+	}
+	if len(v.Tags) > 4 {
+		return fmt.Errorf("bufrw: length %d exceeds 4: %w", len(v.Tags), bufrw.ErrLengthExceeded)
+	}
+	if err := buf.WriteNullableLen(w, len(v.Tags), v.Tags == nil); err != nil {
+		return err
+	}
+	for i14 := range v.Tags {
+		if err := buf.WriteString(w, v.Tags[i14]); err != nil {
+			return err
+		}
+	}
+	if len(v.Raw) > 64 {
+		return fmt.Errorf("bufrw: length %d exceeds 64: %w", len(v.Raw), bufrw.ErrLengthExceeded)
+	}
+	if err := buf.WriteByteValues(w, v.Raw...); err != nil {
+		return err
+	}
+	if err := buf.WriteNullableLen(w, len(v.Sizes), v.Sizes == nil); err != nil {
+		return err
+	}
+	for i15 := range v.Sizes {
+		if err := buf.WriteVarint(w, int64(v.Sizes[i15])); err != nil {
+			return err
+		}
+	}
+	if len(v.Counts) > 8 {
+		return fmt.Errorf("bufrw: length %d exceeds 8: %w", len(v.Counts), bufrw.ErrLengthExceeded)
+	}
+	if err := buf.WriteNullableLen(w, len(v.Counts), v.Counts == nil); err != nil {
+		return err
+	}
+	keys17 := make([]Kind, 0, len(v.Counts))
+	for k16 := range v.Counts {
+		keys17 = append(keys17, k16)
+	}
+	sort.Slice(keys17, func(i, j int) bool { return keys17[i] < keys17[j] })
+	for _, k16 := range keys17 {
+		if err := buf.WriteUvarint(w, uint64(k16)); err != nil {
+			return err
+		}
+		e18 := v.Counts[k16]
+		if err := buf.WriteUvarint(w, uint64(e18)); err != nil {
+			return err
+		}
+	}
+	if err := buf.WriteBool(w, v.Note != nil); err != nil {
+		return err
+	}
+	if v.Note != nil {
+		if err := buf.WriteBool(w, v.Note != nil); err != nil {
+			return err
+		}
+		if v.Note != nil {
+			if err := buf.WriteString(w, (*v.Note)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := buf.WriteBool(w, v.Score != 0); err != nil {
+		return err
+	}
+	if v.Score != 0 {
+		if err := buf.WriteVarint(w, v.Score); err != nil {
+			return err
+		}
+	}
+	if err := buf.WriteBool(w, v.Origin != (Point{})); err != nil {
+		return err
+	}
+	if v.Origin != (Point{}) {
+		if err := buf.WriteInt32(w, v.Origin.X); err != nil {
+			return err
+		}
+		if err := buf.WriteInt32(w, v.Origin.Y); err != nil {
+			return err
+		}
+	}
+	if err := buf.WriteBool(w, v.Deadline != (time.Time{})); err != nil {
+		return err
+	}
+	if v.Deadline != (time.Time{}) {
+		if err := buf.WriteTime(w, v.Deadline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeserializeFromBufRW implements bufrw.SerializableToBufRW.
+func (v *Message) DeserializeFromBufRW(r io.Reader, buf *bufrw.Buffer) error {
+	{
+		val1, err := buf.ReadBool(r)
+		if err != nil {
+			return err
+		}
+		v.Bool = val1
+	}
+	{
+		val2, err := buf.ReadInt64(r)
+		if err != nil {
+			return err
+		}
+		v.Int = int(val2)
+	}
+	{
+		val3, err := buf.ReadInt8(r)
+		if err != nil {
+			return err
+		}
+		v.Int8 = val3
+	}
+	{
+		val4, err := buf.ReadInt16(r)
+		if err != nil {
+			return err
+		}
+		v.Int16 = val4
+	}
+	{
+		val5, err := buf.ReadInt32(r)
+		if err != nil {
+			return err
+		}
+		v.Int32 = val5
+	}
+	{
+		val6, err := buf.ReadInt64(r)
+		if err != nil {
+			return err
+		}
+		v.Int64 = val6
+	}
+	{
+		val7, err := buf.ReadUint64(r)
+		if err != nil {
+			return err
+		}
+		v.Uint = uint(val7)
+	}
+	{
+		val8, err := buf.ReadUint8(r)
+		if err != nil {
+			return err
+		}
+		v.Uint8 = val8
+	}
+	{
+		val9, err := buf.ReadUint16(r)
+		if err != nil {
+			return err
+		}
+		v.Uint16 = val9
+	}
+	{
+		val10, err := buf.ReadUint32(r)
+		if err != nil {
+			return err
+		}
+		v.Uint32 = val10
+	}
+	{
+		val11, err := buf.ReadUint64(r)
+		if err != nil {
+			return err
+		}
+		v.Uint64 = val11
+	}
+	{
+		val12, err := buf.ReadFloat32(r)
+		if err != nil {
+			return err
+		}
+		v.Float32 = val12
+	}
+	{
+		val13, err := buf.ReadFloat64(r)
+		if err != nil {
+			return err
+		}
+		v.Float64 = val13
+	}
+	{
+		val14, err := buf.ReadComplex64(r)
+		if err != nil {
+			return err
+		}
+		v.Complex64 = val14
+	}
+	{
+		val15, err := buf.ReadComplex128(r)
+		if err != nil {
+			return err
+		}
+		v.Complex128 = val15
+	}
+	{
+		val16, err := buf.ReadString(r)
+		if err != nil {
+			return err
+		}
+		v.String = val16
+	}
+	{
+		val17, err := buf.ReadUint16(r)
+		if err != nil {
+			return err
+		}
+		v.Kind = Kind(val17)
+	}
+	{
+		val18, err := buf.ReadByteValues(r)
+		if err != nil {
+			return err
+		}
+		v.Bytes = []byte(val18)
+	}
+	{
+		n20, err := buf.ReadNullableLen(r)
+		if err != nil {
+			return err
+		}
+		var val19 []string
+		if n20 >= 0 {
+			val19 = make([]string, n20)
+			for i21 := range val19 {
+				{
+					val22, err := buf.ReadString(r)
+					if err != nil {
+						return err
+					}
+					val19[i21] = val22
+				}
+			}
+		}
+		v.Strings = val19
+	}
+	for i23 := range v.Array {
+		{
+			val24, err := buf.ReadInt16(r)
+			if err != nil {
+				return err
+			}
+			v.Array[i23] = val24
+		}
+	}
+	{
+		n25, err := buf.ReadNullableLen(r)
+		if err != nil {
+			return err
+		}
+		var m27 map[string][]int64
+		if n25 >= 0 {
+			m27 = make(map[string][]int64, n25)
+		}
+		for i26 := 0; i26 < n25; i26++ {
+			var k28 string
+			{
+				val30, err := buf.ReadString(r)
+				if err != nil {
+					return err
+				}
+				k28 = val30
+			}
+			var e29 []int64
+			{
+				n32, err := buf.ReadNullableLen(r)
+				if err != nil {
+					return err
+				}
+				var val31 []int64
+				if n32 >= 0 {
+					val31 = make([]int64, n32)
+					for i33 := range val31 {
+						{
+							val34, err := buf.ReadInt64(r)
+							if err != nil {
+								return err
+							}
+							val31[i33] = val34
+						}
+					}
+				}
+				e29 = val31
+			}
+			m27[k28] = e29
+		}
+		v.Map = m27
+	}
+	{
+		n35, err := buf.ReadNullableLen(r)
+		if err != nil {
+			return err
+		}
+		var m37 map[int]Point
+		if n35 >= 0 {
+			m37 = make(map[int]Point, n35)
+		}
+		for i36 := 0; i36 < n35; i36++ {
+			var k38 int
+			{
+				val40, err := buf.ReadInt64(r)
+				if err != nil {
+					return err
+				}
+				k38 = int(val40)
+			}
+			var e39 Point
+			{
+				val41, err := buf.ReadInt32(r)
+				if err != nil {
+					return err
+				}
+				e39.X = val41
+			}
+			{
+				val42, err := buf.ReadInt32(r)
+				if err != nil {
+					return err
+				}
+				e39.Y = val42
+			}
+			m37[k38] = e39
+		}
+		v.Points = m37
+	}
+	{
+		n43, err := buf.ReadNullableLen(r)
+		if err != nil {
+			return err
+		}
+		var m45 map[Kind]bool
+		if n43 >= 0 {
+			m45 = make(map[Kind]bool, n43)
+		}
+		for i44 := 0; i44 < n43; i44++ {
+			var k46 Kind
+			{
+				val48, err := buf.ReadUint16(r)
+				if err != nil {
+					return err
+				}
+				k46 = Kind(val48)
+			}
+			var e47 bool
+			{
+				val49, err := buf.ReadBool(r)
+				if err != nil {
+					return err
+				}
+				e47 = val49
+			}
+			m45[k46] = e47
+		}
+		v.Set = m45
+	}
+	{
+		ok50, err := buf.ReadBool(r)
+		if err != nil {
+			return err
+		}
+		if ok50 {
+			p51 := new(Point)
+			{
+				val52, err := buf.ReadInt32(r)
+				if err != nil {
+					return err
+				}
+				(*p51).X = val52
+			}
+			{
+				val53, err := buf.ReadInt32(r)
+				if err != nil {
+					return err
+				}
+				(*p51).Y = val53
+			}
+			v.Pointer = p51
+		} else {
+			v.Pointer = nil
+		}
+	}
+	{
+		ok54, err := buf.ReadBool(r)
+		if err != nil {
+			return err
+		}
+		if ok54 {
+			p55 := new(Node)
+			if err := buf.ReadSerializable(r, p55); err != nil {
+				return err
+			}
+			v.Nested = p55
+		} else {
+			v.Nested = nil
+		}
+	}
+	{
+		n57, err := buf.ReadNullableLen(r)
+		if err != nil {
+			return err
+		}
+		var val56 []Node
+		if n57 >= 0 {
+			val56 = make([]Node, n57)
+			for i58 := range val56 {
+				if err := buf.ReadSerializable(r, &val56[i58]); err != nil {
+					return err
+				}
+			}
+		}
+		v.Nodes = val56
+	}
+	{
+		val59, err := buf.ReadTime(r)
+		if err != nil {
+			return err
+		}
+		v.Time = val59
+	}
+	{
+		val60, err := buf.ReadInt64(r)
+		if err != nil {
+			return err
+		}
+		v.Duration = time.Duration(val60)
+	}
+	{
+		ok61, err := buf.ReadBool(r)
+		if err != nil {
+			return err
+		}
+		if ok61 {
+			p62 := new(big.Int)
+			if err := buf.ReadMarshaler(r, p62); err != nil {
+				return err
+			}
+			v.Amount = p62
+		} else {
+			v.Amount = nil
+		}
+	}
+	if err := buf.ReadMarshaler(r, &v.Link); err != nil {
+		return err
+	}
+	{
+		val63, err := buf.ReadVarint(r)
+		if err != nil {
+			return err
+		}
+		v.ID = val63
+	}
+	{
+		val64, err := buf.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		if uint64(uint16(val64)) != val64 {
+			return fmt.Errorf("bufrw: value %d overflows uint16", val64)
+		}
+		v.Port = uint16(val64)
+	}
+	{
+		val65, err := buf.ReadInt(r)
+		if err != nil {
+			return err
+		}
+		v.Legacy = val65
+	}
+	{
+		val66, err := buf.ReadUint32(r)
+		if err != nil {
+			return err
+		}
+		v.Count = uint64(val66)
+	}
+	{
+		val67, err := buf.ReadPackedBoolsMax(r, 64)
+		if err != nil {
+			return err
+		}
+		v.Flags = []bool(val67)
+	}
+	{
+		val68, err := buf.ReadStringMax(r, 16)
+		if err != nil {
+			return err
+		}
+		v.Name = val68
+	}
+	{
+		n70, err := buf.ReadNullableLen(r)
+		if err != nil {
+			return err
+		}
+		if n70 > 4 {
+			return fmt.Errorf("bufrw: length %d exceeds 4: %w", n70, bufrw.ErrLengthExceeded)
+		}
+		var val69 []string
+		if n70 >= 0 {
+			val69 = make([]string, n70)
+			for i71 := range val69 {
+				{
+					val72, err := buf.ReadString(r)
+					if err != nil {
+						return err
+					}
+					val69[i71] = val72
+				}
+			}
+		}
+		v.Tags = val69
+	}
+	{
+		n74, err := buf.ReadNullableLen(r)
+		if err != nil {
+			return err
+		}
+		if n74 > 64 {
+			return fmt.Errorf("bufrw: length %d exceeds 64: %w", n74, bufrw.ErrLengthExceeded)
+		}
+		var val73 []byte
+		if n74 >= 0 {
+			val73 = make([]byte, n74)
+			if _, err := io.ReadFull(r, val73); err != nil {
+				return err
+			}
+		}
+		v.Raw = val73
+	}
+	{
+		n77, err := buf.ReadNullableLen(r)
+		if err != nil {
+			return err
+		}
+		var val76 []int32
+		if n77 >= 0 {
+			val76 = make([]int32, n77)
+			for i78 := range val76 {
+				{
+					val79, err := buf.ReadVarint(r)
+					if err != nil {
+						return err
+					}
+					if int64(int32(val79)) != val79 {
+						return fmt.Errorf("bufrw: value %d overflows int32", val79)
+					}
+					val76[i78] = int32(val79)
+				}
+			}
+		}
+		v.Sizes = val76
+	}
+	{
+		n80, err := buf.ReadNullableLen(r)
+		if err != nil {
+			return err
+		}
+		if n80 > 8 {
+			return fmt.Errorf("bufrw: length %d exceeds 8: %w", n80, bufrw.ErrLengthExceeded)
+		}
+		var m82 map[Kind]uint32
+		if n80 >= 0 {
+			m82 = make(map[Kind]uint32, n80)
+		}
+		for i81 := 0; i81 < n80; i81++ {
+			var k83 Kind
+			{
+				val85, err := buf.ReadUvarint(r)
+				if err != nil {
+					return err
+				}
+				if uint64(Kind(val85)) != val85 {
+					return fmt.Errorf("bufrw: value %d overflows Kind", val85)
+				}
+				k83 = Kind(val85)
+			}
+			var e84 uint32
+			{
+				val86, err := buf.ReadUvarint(r)
+				if err != nil {
+					return err
+				}
+				if uint64(uint32(val86)) != val86 {
+					return fmt.Errorf("bufrw: value %d overflows uint32", val86)
+				}
+				e84 = uint32(val86)
+			}
+			m82[k83] = e84
+		}
+		v.Counts = m82
+	}
+	{
+		ok87, err := buf.ReadBool(r)
+		if err != nil {
+			return err
+		}
+		if ok87 {
+			{
+				ok88, err := buf.ReadBool(r)
+				if err != nil {
+					return err
+				}
+				if ok88 {
+					p89 := new(string)
+					{
+						val90, err := buf.ReadString(r)
+						if err != nil {
+							return err
+						}
+						(*p89) = val90
+					}
+					v.Note = p89
+				} else {
+					v.Note = nil
+				}
+			}
+		} else {
+			v.Note = nil
+		}
+	}
+	{
+		ok91, err := buf.ReadBool(r)
+		if err != nil {
+			return err
+		}
+		if ok91 {
+			{
+				val92, err := buf.ReadVarint(r)
+				if err != nil {
+					return err
+				}
+				v.Score = val92
+			}
+		} else {
+			v.Score = 0
+		}
+	}
+	{
+		ok93, err := buf.ReadBool(r)
+		if err != nil {
+			return err
+		}
+		if ok93 {
+			{
+				val94, err := buf.ReadInt32(r)
+				if err != nil {
+					return err
+				}
+				v.Origin.X = val94
+			}
+			{
+				val95, err := buf.ReadInt32(r)
+				if err != nil {
+					return err
+				}
+				v.Origin.Y = val95
+			}
+		} else {
+			v.Origin = Point{}
+		}
+	}
+	{
+		ok96, err := buf.ReadBool(r)
+		if err != nil {
+			return err
+		}
+		if ok96 {
+			{
+				val97, err := buf.ReadTime(r)
+				if err != nil {
+					return err
+				}
+				v.Deadline = val97
+			}
+		} else {
+			v.Deadline = time.Time{}
+		}
+	}
+	return nil
+}
+
+// Serialize implements bufrw.Serializable.
+func (v *Message) Serialize() ([]byte, error) {
+	var b bytes.Buffer
+	var buf bufrw.Buffer
+	err := v.SerializeToBufRW(&b, &buf)
+	return b.Bytes(), err
+}
+
+// Deserialize implements bufrw.Serializable.
+func (v *Message) Deserialize(b []byte) error {
+	var buf bufrw.Buffer
+	return v.DeserializeFromBufRW(bytes.NewReader(b), &buf)
+}
+
+// SerializeToBufRW implements bufrw.SerializableToBufRW.
+func (v *Node) SerializeToBufRW(w io.Writer, buf *bufrw.Buffer) error {
+	if err := buf.WriteString(w, v.Name); err != nil {
+		return err
+	}
+	if err := buf.WriteNullableLen(w, len(v.Children), v.Children == nil); err != nil {
+		return err
+	}
+	for i1 := range v.Children {
+		if err := buf.WriteBool(w, v.Children[i1] != nil); err != nil {
+			return err
+		}
+		if v.Children[i1] != nil {
+			if err := buf.WriteSerializable(w, v.Children[i1]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeserializeFromBufRW implements bufrw.SerializableToBufRW.
+func (v *Node) DeserializeFromBufRW(r io.Reader, buf *bufrw.Buffer) error {
+	{
+		val1, err := buf.ReadString(r)
+		if err != nil {
+			return err
+		}
+		v.Name = val1
+	}
+	{
+		n3, err := buf.ReadNullableLen(r)
+		if err != nil {
+			return err
+		}
+		var val2 []*Node
+		if n3 >= 0 {
+			val2 = make([]*Node, n3)
+			for i4 := range val2 {
+				{
+					ok5, err := buf.ReadBool(r)
+					if err != nil {
+						return err
+					}
+					if ok5 {
+						p6 := new(Node)
+						if err := buf.ReadSerializable(r, p6); err != nil {
+							return err
+						}
+						val2[i4] = p6
+					} else {
+						val2[i4] = nil
+					}
+				}
+			}
+		}
+		v.Children = val2
+	}
+	return nil
+}
+
+// Serialize implements bufrw.Serializable.
+func (v *Node) Serialize() ([]byte, error) {
+	var b bytes.Buffer
+	var buf bufrw.Buffer
+	err := v.SerializeToBufRW(&b, &buf)
+	return b.Bytes(), err
+}
+
+// Deserialize implements bufrw.Serializable.
+func (v *Node) Deserialize(b []byte) error {
+	var buf bufrw.Buffer
+	return v.DeserializeFromBufRW(bytes.NewReader(b), &buf)
+}
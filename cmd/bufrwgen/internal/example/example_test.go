@@ -0,0 +1,194 @@
+package example
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/snechholt/bufrw"
+)
+
+// plainMessage has the fields of Message but none of its methods, so that
+// bufrw.Marshal encodes it by reflection.
+type plainMessage Message
+
+func newMessage() Message {
+	note := "note"
+	return Message{
+		Bool:       true,
+		Int:        -1,
+		Int8:       -8,
+		Int16:      -16,
+		Int32:      -32,
+		Int64:      -64,
+		Uint:       1,
+		Uint8:      8,
+		Uint16:     16,
+		Uint32:     32,
+		Uint64:     64,
+		Float32:    1.5,
+		Float64:    -2.5,
+		Complex64:  1 + 2i,
+		Complex128: -3 - 4i,
+		String:     "string",
+		Kind:       7,
+		Bytes:      []byte{1, 2, 3},
+		Strings:    []string{"a", "b"},
+		Array:      [3]int16{1, -2, 3},
+		Map:        map[string][]int64{"a": {1}, "b": {}, "c": {2, 3}},
+		Points:     map[int]Point{-1: {1, 2}, 3: {4, 5}},
+		Set:        map[Kind]bool{1: true, 2: false},
+		Pointer:    &Point{6, 7},
+		Nested: &Node{Name: "root", Children: []*Node{
+			{Name: "child", Children: []*Node{}},
+			nil,
+		}},
+		Nodes:    []Node{{Name: "a", Children: []*Node{}}, {Name: "b", Children: []*Node{}}},
+		Time:     time.Date(2024, 2, 29, 12, 30, 0, 123456789, time.UTC),
+		Duration: 90 * time.Second,
+		Amount:   new(big.Int).Lsh(big.NewInt(-3), 100),
+		Link:     url.URL{Scheme: "https", Host: "example.com", Path: "/a b"},
+		ID:       300,
+		Port:     8080,
+		Legacy:   -5,
+		Count:    7,
+		Flags:    []bool{true, false, true},
+		Name:     "name",
+		Tags:     []string{"x", "y"},
+		Raw:      []byte("raw"),
+		Sizes:    []int32{-1, 0, 1},
+		Counts:   map[Kind]uint32{1: 10, 2: 20},
+		Note:     &note,
+		Score:    -3,
+		Origin:   Point{8, 9},
+		Deadline: time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	value := newMessage()
+	buf := bufrw.NewBuffer(64)
+	var b bytes.Buffer
+	if err := buf.WriteSerializable(&b, &value); err != nil {
+		t.Fatalf("WriteSerializable() error: %v", err)
+	}
+	var got Message
+	if err := buf.ReadSerializable(&b, &got); err != nil {
+		t.Fatalf("ReadSerializable() error: %v", err)
+	}
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("Read %+v, want %+v", got, value)
+	}
+}
+
+func TestMessageMatchesMarshal(t *testing.T) {
+	for _, nullable := range []bool{false, true} {
+		testMessageMatchesMarshal(t, nullable)
+	}
+}
+
+func testMessageMatchesMarshal(t *testing.T, nullable bool) {
+	for _, value := range []Message{{}, newMessage()} {
+		buf := bufrw.NewBuffer(64)
+		buf.SetNullable(nullable)
+		var generated, reflected bytes.Buffer
+		if err := value.SerializeToBufRW(&generated, buf); err != nil {
+			t.Fatalf("SerializeToBufRW() error: %v", err)
+		}
+		if err := buf.Marshal(&reflected, (*plainMessage)(&value)); err != nil {
+			t.Fatalf("Marshal() error: %v", err)
+		}
+		if !bytes.Equal(generated.Bytes(), reflected.Bytes()) {
+			t.Errorf("Generated encoding %x differs from Marshal encoding %x", generated.Bytes(), reflected.Bytes())
+		}
+
+		// Decoding the reflected encoding and encoding the result again
+		// must give the same bytes.
+		want := reflected.Bytes()
+		var got Message
+		if err := got.DeserializeFromBufRW(bytes.NewReader(want), buf); err != nil {
+			t.Fatalf("DeserializeFromBufRW() error: %v", err)
+		}
+		var again bytes.Buffer
+		if err := got.SerializeToBufRW(&again, buf); err != nil {
+			t.Fatalf("SerializeToBufRW() error: %v", err)
+		}
+		if !bytes.Equal(again.Bytes(), want) {
+			t.Errorf("Encoding after decoding is %x, want %x", again.Bytes(), want)
+		}
+		if nullable && !reflect.DeepEqual(got, value) {
+			t.Errorf("Read %+v, want %+v", got, value)
+		}
+	}
+}
+
+func TestMessageLimits(t *testing.T) {
+	value := newMessage()
+	value.Tags = []string{"a", "b", "c", "d", "e"}
+	buf := bufrw.NewBuffer(64)
+	var b bytes.Buffer
+	if err := value.SerializeToBufRW(&b, buf); err == nil {
+		t.Errorf("SerializeToBufRW() of too many tags succeeded")
+	}
+
+	value = newMessage()
+	value.Count = 1 << 32
+	if err := value.SerializeToBufRW(&b, buf); err == nil {
+		t.Errorf("SerializeToBufRW() of overflowing fixed32 value succeeded")
+	}
+}
+
+func TestMessageMaxPrefix(t *testing.T) {
+	// Each case replaces the encoding of a field tagged with max, found by
+	// its value, with a huge length prefix and no value.
+	tests := []struct {
+		name  string
+		set   func(m *Message)
+		write func(buf *bufrw.Buffer, w *bytes.Buffer) error
+	}{
+		{
+			"Name",
+			func(m *Message) { m.Name = "marker" },
+			func(buf *bufrw.Buffer, w *bytes.Buffer) error { return buf.WriteString(w, "marker") },
+		},
+		{
+			"Flags",
+			func(m *Message) { m.Flags = []bool{true, false, true, true, false, true, true, true, false} },
+			func(buf *bufrw.Buffer, w *bytes.Buffer) error {
+				return buf.WritePackedBools(w, true, false, true, true, false, true, true, true, false)
+			},
+		},
+	}
+	for _, test := range tests {
+		value := newMessage()
+		test.set(&value)
+		buf := bufrw.NewBuffer(64)
+		var b, field bytes.Buffer
+		if err := value.SerializeToBufRW(&b, buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := test.write(buf, &field); err != nil {
+			t.Fatal(err)
+		}
+		i := bytes.Index(b.Bytes(), field.Bytes())
+		if i < 0 {
+			t.Fatalf("%s: encoding of field not found", test.name)
+		}
+		b.Truncate(i)
+		if err := buf.WriteLen(&b, 1<<30); err != nil {
+			t.Fatal(err)
+		}
+
+		var got Message
+		if err := got.DeserializeFromBufRW(bytes.NewReader(b.Bytes()), buf); !errors.Is(err, bufrw.ErrLengthExceeded) {
+			t.Errorf("%s: DeserializeFromBufRW() of huge length error is %v, want %v", test.name, err, bufrw.ErrLengthExceeded)
+		}
+		if err := buf.Unmarshal(bytes.NewReader(b.Bytes()), (*plainMessage)(&got)); !errors.Is(err, bufrw.ErrLengthExceeded) {
+			t.Errorf("%s: Unmarshal() of huge length error is %v, want %v", test.name, err, bufrw.ErrLengthExceeded)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package bufrw
+
+import (
+	"io"
+	"sort"
+)
+
+// Ordered is a constraint permitting the types whose values are ordered by
+// the < operator.
+type Ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 |
+		~string
+}
+
+// WriteSlice writes zero or more values to w, calling write to write each
+// value. Method expressions of Buffer such as (*Buffer).WriteString can be
+// passed as write.
+func WriteSlice[T any](buf *Buffer, w io.Writer, val []T, write func(*Buffer, io.Writer, T) error) error {
+	if err := buf.WriteNullableLen(w, len(val), val == nil); err != nil {
+		return err
+	}
+	for _, v := range val {
+		if err := write(buf, w, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSlice reads zero or more values from r, where r reads from a source
+// that has used WriteSlice to write the values, calling read to read each
+// value.
+func ReadSlice[T any](buf *Buffer, r io.Reader, read func(*Buffer, io.Reader) (T, error)) ([]T, error) {
+	return readSlice(buf, r, 1, read)
+}
+
+// readSlice is like ReadSlice, for values taking at least elemSize bytes
+// each.
+func readSlice[T any](buf *Buffer, r io.Reader, elemSize int, read func(*Buffer, io.Reader) (T, error)) ([]T, error) {
+	n, err := buf.readNullableLen(r, buf.limits.MaxSliceLen, elemSize)
+	if err != nil || n == nilLen {
+		return nil, err
+	}
+	values := make([]T, n)
+	for i := range values {
+		if values[i], err = read(buf, r); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// WriteMap writes the entries of m to w in map iteration order, calling
+// writeKey and writeValue to write the key and value of each entry. Use
+// WriteSortedMap for an encoding that does not vary between calls.
+func WriteMap[K comparable, V any](buf *Buffer, w io.Writer, m map[K]V, writeKey func(*Buffer, io.Writer, K) error, writeValue func(*Buffer, io.Writer, V) error) error {
+	if err := buf.WriteNullableLen(w, len(m), m == nil); err != nil {
+		return err
+	}
+	for k, v := range m {
+		if err := writeMapEntry(buf, w, k, v, writeKey, writeValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSortedMap is like WriteMap, but writes the entries of m in
+// ascending key order, so that equal maps always have the same encoding.
+// This is the order used by Marshal.
+func WriteSortedMap[K Ordered, V any](buf *Buffer, w io.Writer, m map[K]V, writeKey func(*Buffer, io.Writer, K) error, writeValue func(*Buffer, io.Writer, V) error) error {
+	if err := buf.WriteNullableLen(w, len(m), m == nil); err != nil {
+		return err
+	}
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, k := range keys {
+		if err := writeMapEntry(buf, w, k, m[k], writeKey, writeValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMapEntry[K comparable, V any](buf *Buffer, w io.Writer, k K, v V, writeKey func(*Buffer, io.Writer, K) error, writeValue func(*Buffer, io.Writer, V) error) error {
+	if err := writeKey(buf, w, k); err != nil {
+		return err
+	}
+	return writeValue(buf, w, v)
+}
+
+// ReadMap reads a map from r, where r reads from a source that has used
+// WriteMap or WriteSortedMap to write the map, calling readKey and
+// readValue to read the key and value of each entry.
+func ReadMap[K comparable, V any](buf *Buffer, r io.Reader, readKey func(*Buffer, io.Reader) (K, error), readValue func(*Buffer, io.Reader) (V, error)) (map[K]V, error) {
+	n, err := buf.readNullableLen(r, buf.limits.MaxSliceLen, 1)
+	if err != nil || n == nilLen {
+		return nil, err
+	}
+	m := make(map[K]V, n)
+	for i := 0; i < n; i++ {
+		k, err := readKey(buf, r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readValue(buf, r)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,100 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+type point struct {
+	X, Y int32
+}
+
+func writePoint(buf *Buffer, w io.Writer, p point) error {
+	if err := buf.WriteInt32(w, p.X); err != nil {
+		return err
+	}
+	return buf.WriteInt32(w, p.Y)
+}
+
+func readPoint(buf *Buffer, r io.Reader) (point, error) {
+	var p point
+	var err error
+	if p.X, err = buf.ReadInt32(r); err != nil {
+		return p, err
+	}
+	p.Y, err = buf.ReadInt32(r)
+	return p, err
+}
+
+func writeInt64s(buf *Buffer, w io.Writer, val []int64) error {
+	return buf.WriteInt64s(w, val...)
+}
+
+func TestBufferReadWriteSlice(t *testing.T) {
+	testReadWrite(t, [][]point{{}, {{1, 2}}, {{-1, 0}, {3, 4}}}, func(buf *Buffer, w io.Writer, val []point) error {
+		return WriteSlice(buf, w, val, writePoint)
+	}, func(buf *Buffer, r io.Reader) ([]point, error) {
+		return ReadSlice(buf, r, readPoint)
+	})
+
+	// Slices of slices compose from the plural methods.
+	testReadWrite(t, [][][]string{{}, {{}, {"a"}, {"b", "c"}}}, func(buf *Buffer, w io.Writer, val [][]string) error {
+		return WriteSlice(buf, w, val, func(buf *Buffer, w io.Writer, v []string) error {
+			return buf.WriteStrings(w, v...)
+		})
+	}, func(buf *Buffer, r io.Reader) ([][]string, error) {
+		return ReadSlice(buf, r, (*Buffer).ReadStrings)
+	})
+}
+
+func TestBufferReadWriteMap(t *testing.T) {
+	values := []map[string][]int64{
+		{},
+		{"a": {1}},
+		{"a": {1, 2}, "b": {}, "c": {-3}},
+	}
+	read := func(buf *Buffer, r io.Reader) (map[string][]int64, error) {
+		return ReadMap(buf, r, (*Buffer).ReadString, (*Buffer).ReadInt64s)
+	}
+	testReadWrite(t, values, func(buf *Buffer, w io.Writer, val map[string][]int64) error {
+		return WriteMap(buf, w, val, (*Buffer).WriteString, writeInt64s)
+	}, read)
+	testReadWrite(t, values, func(buf *Buffer, w io.Writer, val map[string][]int64) error {
+		return WriteSortedMap(buf, w, val, (*Buffer).WriteString, writeInt64s)
+	}, read)
+}
+
+func TestWriteSortedMapMatchesMarshal(t *testing.T) {
+	m := make(map[int64]string)
+	for i := int64(0); i < 20; i++ {
+		m[i*7%20-10] = string(rune('a' + i))
+	}
+	var buf Buffer
+	var want bytes.Buffer
+	if err := buf.Marshal(&want, m); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		var got bytes.Buffer
+		if err := WriteSortedMap(&buf, &got, m, (*Buffer).WriteInt64, (*Buffer).WriteString); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Fatalf("WriteSortedMap wrote %x, want %x", got.Bytes(), want.Bytes())
+		}
+	}
+}
+
+func TestReadSliceLimits(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	if err := WriteSlice(&buf, &b, []point{{1, 2}, {3, 4}, {5, 6}}, writePoint); err != nil {
+		t.Fatal(err)
+	}
+	buf.SetLimits(Limits{MaxSliceLen: 2})
+	if _, err := ReadSlice(&buf, &b, readPoint); !errors.Is(err, ErrLengthExceeded) {
+		t.Errorf("ReadSlice() error is %v, want %v", err, ErrLengthExceeded)
+	}
+}
@@ -0,0 +1,119 @@
+package bufrw
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Floats are written as their IEEE 754 bits, so NaN payloads, signed zeros
+// and infinities are read back exactly as they were written.
+
+// WriteFloat32 writes a float32 value to w.
+func (buf *Buffer) WriteFloat32(w io.Writer, val float32) error {
+	return buf.WriteUint32(w, math.Float32bits(val))
+}
+
+// ReadFloat32 reads a float32 value from r, where r reads from a source
+// that has used WriteFloat32 to write a float32 value.
+func (buf *Buffer) ReadFloat32(r io.Reader) (float32, error) {
+	val, err := buf.ReadUint32(r)
+	return math.Float32frombits(val), err
+}
+
+// WriteFloat32s writes zero or more float32 values to w.
+func (buf *Buffer) WriteFloat32s(w io.Writer, val ...float32) error {
+	order := buf.byteOrder()
+	return writeValues(buf, w, val, 4, func(b []byte, v float32) { order.PutUint32(b, math.Float32bits(v)) })
+}
+
+// ReadFloat32s reads zero or more float32 values from r, where r reads
+// from a source that has used WriteFloat32s to write the float32 values.
+func (buf *Buffer) ReadFloat32s(r io.Reader) ([]float32, error) {
+	order := buf.byteOrder()
+	return readValues(buf, r, 4, func(b []byte) float32 { return math.Float32frombits(order.Uint32(b)) })
+}
+
+// WriteComplex64 writes a complex64 value to w, as its real part followed
+// by its imaginary part.
+func (buf *Buffer) WriteComplex64(w io.Writer, val complex64) error {
+	b := buf.borrow(8)
+	putComplex64(buf.byteOrder(), b, val)
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadComplex64 reads a complex64 value from r, where r reads from a
+// source that has used WriteComplex64 to write a complex64 value.
+func (buf *Buffer) ReadComplex64(r io.Reader) (complex64, error) {
+	b, err := buf.Read(r, 8)
+	if err != nil {
+		return 0, err
+	}
+	return getComplex64(buf.byteOrder(), b), nil
+}
+
+// WriteComplex64s writes zero or more complex64 values to w.
+func (buf *Buffer) WriteComplex64s(w io.Writer, val ...complex64) error {
+	order := buf.byteOrder()
+	return writeValues(buf, w, val, 8, func(b []byte, v complex64) { putComplex64(order, b, v) })
+}
+
+// ReadComplex64s reads zero or more complex64 values from r, where r
+// reads from a source that has used WriteComplex64s to write the
+// complex64 values.
+func (buf *Buffer) ReadComplex64s(r io.Reader) ([]complex64, error) {
+	order := buf.byteOrder()
+	return readValues(buf, r, 8, func(b []byte) complex64 { return getComplex64(order, b) })
+}
+
+// WriteComplex128 writes a complex128 value to w, as its real part
+// followed by its imaginary part.
+func (buf *Buffer) WriteComplex128(w io.Writer, val complex128) error {
+	b := buf.borrow(16)
+	putComplex128(buf.byteOrder(), b, val)
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadComplex128 reads a complex128 value from r, where r reads from a
+// source that has used WriteComplex128 to write a complex128 value.
+func (buf *Buffer) ReadComplex128(r io.Reader) (complex128, error) {
+	b, err := buf.Read(r, 16)
+	if err != nil {
+		return 0, err
+	}
+	return getComplex128(buf.byteOrder(), b), nil
+}
+
+// WriteComplex128s writes zero or more complex128 values to w.
+func (buf *Buffer) WriteComplex128s(w io.Writer, val ...complex128) error {
+	order := buf.byteOrder()
+	return writeValues(buf, w, val, 16, func(b []byte, v complex128) { putComplex128(order, b, v) })
+}
+
+// ReadComplex128s reads zero or more complex128 values from r, where r
+// reads from a source that has used WriteComplex128s to write the
+// complex128 values.
+func (buf *Buffer) ReadComplex128s(r io.Reader) ([]complex128, error) {
+	order := buf.byteOrder()
+	return readValues(buf, r, 16, func(b []byte) complex128 { return getComplex128(order, b) })
+}
+
+func putComplex64(order binary.ByteOrder, b []byte, v complex64) {
+	order.PutUint32(b, math.Float32bits(real(v)))
+	order.PutUint32(b[4:], math.Float32bits(imag(v)))
+}
+
+func getComplex64(order binary.ByteOrder, b []byte) complex64 {
+	return complex(math.Float32frombits(order.Uint32(b)), math.Float32frombits(order.Uint32(b[4:])))
+}
+
+func putComplex128(order binary.ByteOrder, b []byte, v complex128) {
+	order.PutUint64(b, math.Float64bits(real(v)))
+	order.PutUint64(b[8:], math.Float64bits(imag(v)))
+}
+
+func getComplex128(order binary.ByteOrder, b []byte) complex128 {
+	return complex(math.Float64frombits(order.Uint64(b)), math.Float64frombits(order.Uint64(b[8:])))
+}
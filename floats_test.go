@@ -0,0 +1,168 @@
+package bufrw
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+// float32Specials and float64Specials hold bit patterns that must survive
+// a round trip unchanged: signed zeros, infinities, NaNs with distinct
+// payloads, including signaling NaNs, and subnormal and extreme values.
+var (
+	float32Specials = []uint32{
+		0x00000000, // +0
+		0x80000000, // -0
+		0x7f800000, // +Inf
+		0xff800000, // -Inf
+		0x7fc00000, // quiet NaN
+		0xffc00001, // negative quiet NaN with payload
+		0x7f800001, // signaling NaN
+		0x7fa5a5a5, // signaling NaN with payload
+		0x00000001, // smallest subnormal
+		0x7f7fffff, // largest finite
+	}
+	float64Specials = []uint64{
+		0x0000000000000000, // +0
+		0x8000000000000000, // -0
+		0x7ff0000000000000, // +Inf
+		0xfff0000000000000, // -Inf
+		0x7ff8000000000000, // quiet NaN
+		0xfff8000000000001, // negative quiet NaN with payload
+		0x7ff0000000000001, // signaling NaN
+		0x7ff5a5a5a5a5a5a5, // signaling NaN with payload
+		0x0000000000000001, // smallest subnormal
+		0x7fefffffffffffff, // largest finite
+	}
+)
+
+// testFloatBits writes the values with write and reads them back with
+// read, failing the test unless the bits of each value are unchanged.
+func testFloatBits[T any, B comparable](t *testing.T, values []T, bits func(T) B, write func(*Buffer, io.Writer, []T) error, read func(*Buffer, io.Reader) ([]T, error)) {
+	t.Helper()
+	var buf Buffer
+	var w bytes.Buffer
+	if err := write(&buf, &w, values); err != nil {
+		t.Fatal(err)
+	}
+	got, err := read(&buf, &w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(values) {
+		t.Fatalf("Read %d values, want %d", len(got), len(values))
+	}
+	for i := range values {
+		if bits(got[i]) != bits(values[i]) {
+			t.Errorf("Write/Read of value %d has bits %v, want %v", i, bits(got[i]), bits(values[i]))
+		}
+	}
+}
+
+func TestBufferReadWriteFloat32Bits(t *testing.T) {
+	values := make([]float32, len(float32Specials))
+	for i, b := range float32Specials {
+		values[i] = math.Float32frombits(b)
+	}
+	single := func(buf *Buffer, w io.Writer, val []float32) error {
+		for _, v := range val {
+			if err := buf.WriteFloat32(w, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	readSingle := func(buf *Buffer, r io.Reader) ([]float32, error) {
+		values := make([]float32, len(float32Specials))
+		for i := range values {
+			var err error
+			if values[i], err = buf.ReadFloat32(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	testFloatBits(t, values, math.Float32bits, single, readSingle)
+	testFloatBits(t, values, math.Float32bits, func(buf *Buffer, w io.Writer, val []float32) error {
+		return buf.WriteFloat32s(w, val...)
+	}, (*Buffer).ReadFloat32s)
+}
+
+func TestBufferReadWriteFloat64Bits(t *testing.T) {
+	values := make([]float64, len(float64Specials))
+	for i, b := range float64Specials {
+		values[i] = math.Float64frombits(b)
+	}
+	single := func(buf *Buffer, w io.Writer, val []float64) error {
+		for _, v := range val {
+			if err := buf.WriteFloat64(w, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	readSingle := func(buf *Buffer, r io.Reader) ([]float64, error) {
+		values := make([]float64, len(float64Specials))
+		for i := range values {
+			var err error
+			if values[i], err = buf.ReadFloat64(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	testFloatBits(t, values, math.Float64bits, single, readSingle)
+	testFloatBits(t, values, math.Float64bits, func(buf *Buffer, w io.Writer, val []float64) error {
+		return buf.WriteFloat64s(w, val...)
+	}, (*Buffer).ReadFloat64s)
+}
+
+func TestBufferReadWriteComplexBits(t *testing.T) {
+	var values64 []complex64
+	for _, re := range float32Specials {
+		for _, im := range float32Specials {
+			values64 = append(values64, complex(math.Float32frombits(re), math.Float32frombits(im)))
+		}
+	}
+	bits64 := func(v complex64) [2]uint32 {
+		return [2]uint32{math.Float32bits(real(v)), math.Float32bits(imag(v))}
+	}
+	testFloatBits(t, values64, bits64, func(buf *Buffer, w io.Writer, val []complex64) error {
+		return buf.WriteComplex64s(w, val...)
+	}, (*Buffer).ReadComplex64s)
+
+	var values128 []complex128
+	for _, re := range float64Specials {
+		for _, im := range float64Specials {
+			values128 = append(values128, complex(math.Float64frombits(re), math.Float64frombits(im)))
+		}
+	}
+	bits128 := func(v complex128) [2]uint64 {
+		return [2]uint64{math.Float64bits(real(v)), math.Float64bits(imag(v))}
+	}
+	testFloatBits(t, values128, bits128, func(buf *Buffer, w io.Writer, val []complex128) error {
+		return buf.WriteComplex128s(w, val...)
+	}, (*Buffer).ReadComplex128s)
+}
+
+func TestBufferReadWriteComplex(t *testing.T) {
+	testReadWrite(t, []complex64{0, 1 + 2i, -1.5 - 0.25i, complex(math.MaxFloat32, -math.MaxFloat32)}, (*Buffer).WriteComplex64, (*Buffer).ReadComplex64)
+	testReadWrite(t, []complex128{0, 1 + 2i, -1.5 - 0.25i, complex(math.MaxFloat64, -math.SmallestNonzeroFloat64)}, (*Buffer).WriteComplex128, (*Buffer).ReadComplex128)
+	testReadWrite(t, []float32{0, 1.5, -math.MaxFloat32, math.SmallestNonzeroFloat32}, (*Buffer).WriteFloat32, (*Buffer).ReadFloat32)
+}
+
+func TestBufferFloat32WireFormat(t *testing.T) {
+	var buf Buffer
+	var w bytes.Buffer
+	if err := buf.WriteFloat32(&w, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := buf.WriteComplex64(&w, complex(1, -2)); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x3f, 0x80, 0, 0, 0x3f, 0x80, 0, 0, 0xc0, 0, 0, 0}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("Wrote %x, want %x", w.Bytes(), want)
+	}
+}
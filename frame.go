@@ -0,0 +1,240 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+var (
+	// ErrFrameTooLarge is returned when a frame exceeds the maximum frame
+	// size of a FrameWriter or FrameReader.
+	ErrFrameTooLarge = errors.New("bufrw: frame exceeds maximum size")
+
+	// ErrChecksumMismatch is returned, wrapped in a *ChecksumError, when
+	// the checksum of a frame does not match its contents.
+	ErrChecksumMismatch = errors.New("bufrw: checksum mismatch")
+)
+
+// ChecksumError describes a frame whose checksum does not match its
+// contents, recording the offset of the frame in the input.
+type ChecksumError struct {
+	Offset int64
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("bufrw: checksum mismatch in frame at offset %d", e.Offset)
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// NewCRC32C returns a new hash computing the CRC-32 checksum using the
+// Castagnoli polynomial, for use with SetChecksum.
+func NewCRC32C() hash.Hash32 {
+	return crc32.New(castagnoli)
+}
+
+// frameHeaderSize is the size of the length header of a frame.
+const frameHeaderSize = 4
+
+// DefaultMaxFrameSize is the maximum frame size of a FrameWriter or
+// FrameReader created with a maxSize of zero or less, if the buffer has no
+// MaxMessageBytes limit.
+const DefaultMaxFrameSize = 16 << 20
+
+// maxFrameSize returns maxSize if it is positive, or else the
+// MaxMessageBytes limit of the buffer or DefaultMaxFrameSize.
+func (buf *Buffer) maxFrameSize(maxSize int) int {
+	if maxSize > 0 {
+		return maxSize
+	}
+	if m := buf.limits.MaxMessageBytes; m > 0 {
+		if m > math.MaxInt {
+			m = math.MaxInt
+		}
+		return int(m)
+	}
+	return DefaultMaxFrameSize
+}
+
+// FrameWriter writes messages as frames, each preceded by a header holding
+// its length in bytes with WriteUint32, so that a FrameReader can read each
+// message as a whole and recover from messages it fails to decode.
+type FrameWriter struct {
+	buf      *Buffer
+	w        io.Writer
+	maxSize  int
+	msg      bytes.Buffer
+	frame    *Writer
+	checksum hash.Hash
+}
+
+// FrameWriter returns a FrameWriter writing frames of at most maxSize
+// bytes to w. A maxSize of zero or less means the MaxMessageBytes limit of
+// buf, or DefaultMaxFrameSize if it has none.
+func (buf *Buffer) FrameWriter(w io.Writer, maxSize int) *FrameWriter {
+	return &FrameWriter{buf: buf, w: w, maxSize: buf.maxFrameSize(maxSize)}
+}
+
+// SetChecksum sets the hash computing the checksum of each frame, such as
+// the one returned by NewCRC32C, or nil, the default, for no checksum. The
+// checksum of the header and values of a frame is written after the
+// values. Frames must be read by a FrameReader using the same hash.
+func (fw *FrameWriter) SetChecksum(h hash.Hash) {
+	fw.checksum = h
+}
+
+// Begin begins a frame, returning a Writer for its values. The values are
+// buffered until End is called. The returned Writer stops on errors.
+func (fw *FrameWriter) Begin() *Writer {
+	fw.msg.Reset()
+	fw.msg.Write(make([]byte, frameHeaderSize))
+	fw.frame = fw.buf.Writer(&fw.msg, true)
+	return fw.frame
+}
+
+// End ends the frame begun with Begin, writing its header and values to
+// the underlying writer with a single call to Write. If writing a value of
+// the frame failed or the frame exceeds the maximum frame size, nothing is
+// written and the error is returned.
+func (fw *FrameWriter) End() error {
+	frame := fw.frame
+	if frame == nil {
+		return errors.New("bufrw: End of a FrameWriter without Begin")
+	}
+	fw.frame = nil
+	if err := frame.Err(); err != nil {
+		return err
+	}
+	b := fw.msg.Bytes()
+	n := len(b) - frameHeaderSize
+	if err := checkFrameSize(n, fw.maxSize); err != nil {
+		return err
+	}
+	fw.buf.byteOrder().PutUint32(b, uint32(n))
+	if h := fw.checksum; h != nil {
+		h.Reset()
+		h.Write(b)
+		b = h.Sum(b)
+	}
+	_, err := fw.w.Write(b)
+	return err
+}
+
+// checkFrameSize fails with ErrFrameTooLarge if a frame of n bytes exceeds
+// maxSize or the length header.
+func checkFrameSize(n, maxSize int) error {
+	if n > maxSize || int64(n) > math.MaxUint32 {
+		return fmt.Errorf("bufrw: frame of %d bytes: %w", n, ErrFrameTooLarge)
+	}
+	return nil
+}
+
+// FrameReader reads messages written as frames by a FrameWriter. Each
+// frame is read as a whole into memory borrowed from a buffer owned by the
+// FrameReader, which is reused for every frame and grows no larger than
+// the maximum frame size, and its values are read with a Reader scoped to
+// the frame. An error reading the values of a
+// frame does not affect the following frames.
+type FrameReader struct {
+	buf      *Buffer
+	r        io.Reader
+	maxSize  int
+	mem      Buffer
+	frame    bytes.Reader
+	offset   int64
+	next     int64
+	checksum hash.Hash
+	sum      []byte
+}
+
+// FrameReader returns a FrameReader reading frames of at most maxSize
+// bytes from r, whose values are read using buf. A maxSize of zero or less
+// means the MaxMessageBytes limit of buf, or DefaultMaxFrameSize if it has
+// none. The length header of each frame is checked against the maximum
+// before any memory is allocated for the frame.
+func (buf *Buffer) FrameReader(r io.Reader, maxSize int) *FrameReader {
+	fr := &FrameReader{buf: buf, r: r, maxSize: buf.maxFrameSize(maxSize)}
+	fr.mem.maxSize = fr.maxSize
+	return fr
+}
+
+// SetChecksum sets the hash verifying the checksum of each frame, which
+// must be the one used by the FrameWriter, or nil, the default, for no
+// checksum. Frames are verified before their values are read.
+func (fr *FrameReader) SetChecksum(h hash.Hash) {
+	fr.checksum = h
+	// The memory of the frame also holds its checksum.
+	fr.mem.maxSize = fr.maxSize
+	if h != nil {
+		fr.mem.maxSize += h.Size()
+	}
+}
+
+// Next reads the next frame, returning a Reader limited to its values,
+// which stops on errors. The Reader is valid until the next call to Next.
+// Next returns io.EOF if the input ends before the next frame. A frame
+// exceeding the maximum frame size is discarded and ErrFrameTooLarge
+// returned, so that the following frame can be read. Likewise, a frame
+// whose checksum does not match its contents is discarded and a
+// *ChecksumError returned.
+func (fr *FrameReader) Next() (*Reader, error) {
+	fr.offset = fr.next
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(fr.r, header[:]); err != nil {
+		return nil, err
+	}
+	n := int64(fr.buf.byteOrder().Uint32(header[:]))
+	var sumSize int64
+	if fr.checksum != nil {
+		sumSize = int64(fr.checksum.Size())
+	}
+	fr.next += frameHeaderSize + n + sumSize
+	if n > int64(fr.maxSize) {
+		if err := discard(fr.r, n+sumSize); err != nil {
+			return nil, noEOF(err)
+		}
+		return nil, fmt.Errorf("bufrw: frame of %d bytes at offset %d: %w", n, fr.offset, ErrFrameTooLarge)
+	}
+	b, err := fr.mem.Read(fr.r, int(n+sumSize))
+	if err != nil {
+		return nil, noEOF(err)
+	}
+	b, sum := b[:n], b[n:]
+	if h := fr.checksum; h != nil {
+		h.Reset()
+		h.Write(header[:])
+		h.Write(b)
+		if fr.sum = h.Sum(fr.sum[:0]); !bytes.Equal(fr.sum, sum) {
+			return nil, &ChecksumError{Offset: fr.offset}
+		}
+	}
+	fr.frame.Reset(b)
+	return &Reader{
+		buf:         fr.buf,
+		r:           &countingReader{r: &fr.frame, max: n},
+		stopOnError: true,
+	}, nil
+}
+
+// Offset returns the offset in the input of the frame last read by Next.
+func (fr *FrameReader) Offset() int64 {
+	return fr.offset
+}
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF, for inputs ending within
+// a frame.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
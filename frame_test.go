@@ -0,0 +1,246 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"hash/crc64"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestFrameReadWrite(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		defer client.Close()
+		var buf Buffer
+		fw := buf.FrameWriter(client, 1024)
+		for i := 0; i < 3; i++ {
+			w := fw.Begin()
+			w.WriteInt(i)
+			w.WriteString("frame")
+			if err := fw.End(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	var buf Buffer
+	fr := buf.FrameReader(server, 1024)
+	for i := 0; ; i++ {
+		r, err := fr.Next()
+		if err == io.EOF {
+			if i != 3 {
+				t.Errorf("Read %d frames, want 3", i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _ := r.ReadInt()
+		s, _ := r.ReadString()
+		if err := r.Err(); err != nil || n != i || s != "frame" {
+			t.Errorf("Frame %d = %d, %q, %v", i, n, s, err)
+		}
+		if want := int64(i * 17); fr.Offset() != want {
+			t.Errorf("Offset() of frame %d = %d, want %d", i, fr.Offset(), want)
+		}
+	}
+}
+
+func TestFrameBadMessage(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	fw := buf.FrameWriter(&b, 0)
+	fw.Begin().WriteString("not an int64 slice")
+	fw.End()
+	fw.Begin().WriteInt64(42)
+	if err := fw.End(); err != nil {
+		t.Fatal(err)
+	}
+
+	fr := buf.FrameReader(&b, 0)
+	r, err := fr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadInt64s(); err == nil {
+		t.Error("ReadInt64s() of string succeeded")
+	}
+	if r, err = fr.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := r.ReadInt64(); i != 42 || err != nil {
+		t.Errorf("ReadInt64() of next frame = %d, %v, want 42", i, err)
+	}
+	if _, err := r.ReadInt64(); !errors.Is(err, ErrLengthExceeded) {
+		t.Errorf("ReadInt64() past frame error is %v, want %v", err, ErrLengthExceeded)
+	}
+}
+
+func TestFrameTooLarge(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	fw := buf.FrameWriter(&b, 8)
+	fw.Begin().WriteString("too long for the frame")
+	if err := fw.End(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("End() of large frame error is %v, want %v", err, ErrFrameTooLarge)
+	}
+	if b.Len() != 0 {
+		t.Errorf("End() of large frame wrote %d bytes", b.Len())
+	}
+
+	fw = buf.FrameWriter(&b, 0)
+	fw.Begin().WriteString("too long for the frame")
+	fw.End()
+	fw.Begin().WriteInt(7)
+	if err := fw.End(); err != nil {
+		t.Fatal(err)
+	}
+	fr := buf.FrameReader(&b, 8)
+	if _, err := fr.Next(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("Next() of large frame error is %v, want %v", err, ErrFrameTooLarge)
+	}
+	r, err := fr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, err := r.ReadInt(); i != 7 || err != nil {
+		t.Errorf("ReadInt() after large frame = %d, %v, want 7", i, err)
+	}
+}
+
+func TestFrameHugeHeader(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	if err := buf.WriteUint32(&b, 0xFFFFFFF0); err != nil {
+		t.Fatal(err)
+	}
+	b.WriteString("not the claimed frame")
+	fr := buf.FrameReader(bytes.NewReader(b.Bytes()), 0)
+	if _, err := fr.Next(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("Next() of huge frame error is %v, want %v", err, ErrFrameTooLarge)
+	}
+	if cap(fr.mem.b) != 0 {
+		t.Errorf("Next() of huge frame allocated %d bytes", cap(fr.mem.b))
+	}
+	if fr.mem.maxSize != DefaultMaxFrameSize {
+		t.Errorf("Frame memory limit = %d, want %d", fr.mem.maxSize, DefaultMaxFrameSize)
+	}
+
+	buf.SetLimits(Limits{MaxMessageBytes: 8})
+	fw := buf.FrameWriter(&b, 0)
+	fw.Begin().WriteString("too long for the limit")
+	if err := fw.End(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Errorf("End() of frame beyond MaxMessageBytes error is %v, want %v", err, ErrFrameTooLarge)
+	}
+}
+
+func TestFrameTruncated(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	fw := buf.FrameWriter(&b, 0)
+	fw.Begin().WriteInt64(1)
+	if err := fw.End(); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{2, b.Len() - 1} {
+		fr := buf.FrameReader(bytes.NewReader(b.Bytes()[:n]), 0)
+		if _, err := fr.Next(); err != io.ErrUnexpectedEOF {
+			t.Errorf("Next() of %d bytes error is %v, want %v", n, err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestFrameChecksum(t *testing.T) {
+	hashes := []func() hash.Hash{
+		func() hash.Hash { return NewCRC32C() },
+		func() hash.Hash { return crc64.New(crc64.MakeTable(crc64.ECMA)) },
+	}
+	for _, newHash := range hashes {
+		var buf Buffer
+		var b bytes.Buffer
+		fw := buf.FrameWriter(&b, 64)
+		fw.SetChecksum(newHash())
+		for _, s := range []string{"alpha", "bravo", "delta"} {
+			fw.Begin().WriteString(s)
+			if err := fw.End(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Corrupt the last byte of the value of the second of the equally
+		// sized frames.
+		size := b.Len() / 3
+		data := b.Bytes()
+		data[2*size-newHash().Size()-1] ^= 1
+
+		fr := buf.FrameReader(bytes.NewReader(data), 64)
+		fr.SetChecksum(newHash())
+		var got []string
+		var checksumErr *ChecksumError
+		for {
+			r, err := fr.Next()
+			if err == io.EOF {
+				break
+			}
+			if errors.As(err, &checksumErr) {
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := r.ReadString()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, s)
+		}
+		if len(got) != 2 || got[0] != "alpha" || got[1] != "delta" {
+			t.Errorf("Read frames %q, want alpha and delta", got)
+		}
+		if checksumErr == nil || checksumErr.Offset != int64(size) || !errors.Is(checksumErr, ErrChecksumMismatch) {
+			t.Errorf("Next() of corrupt frame error is %v, want checksum mismatch at offset %d", checksumErr, size)
+		}
+	}
+}
+
+func TestFrameChecksumToggle(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	fw := buf.FrameWriter(&b, 8)
+	checksums := []hash.Hash{nil, NewCRC32C(), nil}
+	for _, h := range checksums {
+		fw.SetChecksum(h)
+		fw.Begin().WriteInt64(42)
+		if err := fw.End(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fr := buf.FrameReader(&b, 8)
+	for i, h := range checksums {
+		fr.SetChecksum(h)
+		want := 8
+		if h != nil {
+			want += h.Size()
+		}
+		if fr.mem.maxSize != want {
+			t.Errorf("Frame memory limit of frame %d = %d, want %d", i, fr.mem.maxSize, want)
+		}
+		r, err := fr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := r.ReadInt64(); n != 42 || err != nil {
+			t.Errorf("ReadInt64() of frame %d = %d, %v, want 42", i, n, err)
+		}
+	}
+	if n := len(fr.mem.b); n != 12 {
+		t.Errorf("Frame memory is %d bytes, want 12", n)
+	}
+}
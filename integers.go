@@ -0,0 +1,216 @@
+package bufrw
+
+import "io"
+
+// WriteInt8 writes an int8 value to w as 1 byte.
+func (buf *Buffer) WriteInt8(w io.Writer, val int8) error {
+	b := buf.borrow(1)
+	b[0] = byte(val)
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadInt8 reads an int8 value from r, where r reads from a source
+// that has used WriteInt8 to write an int8 value.
+func (buf *Buffer) ReadInt8(r io.Reader) (int8, error) {
+	b, err := buf.Read(r, 1)
+	if err != nil {
+		return 0, err
+	}
+	return int8(b[0]), nil
+}
+
+// WriteInt8s writes zero or more int8 values to w.
+func (buf *Buffer) WriteInt8s(w io.Writer, val ...int8) error {
+	return writeValues(buf, w, val, 1, func(b []byte, v int8) { b[0] = byte(v) })
+}
+
+// ReadInt8s reads zero or more int8 values from r, where r reads
+// from a source that has used WriteInt8s to write the int8 values.
+func (buf *Buffer) ReadInt8s(r io.Reader) ([]int8, error) {
+	return readValues(buf, r, 1, func(b []byte) int8 { return int8(b[0]) })
+}
+
+// WriteInt16 writes an int16 value to w as 2 bytes.
+func (buf *Buffer) WriteInt16(w io.Writer, val int16) error {
+	b := buf.borrow(2)
+	buf.byteOrder().PutUint16(b, uint16(val))
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadInt16 reads an int16 value from r, where r reads from a source
+// that has used WriteInt16 to write an int16 value.
+func (buf *Buffer) ReadInt16(r io.Reader) (int16, error) {
+	b, err := buf.Read(r, 2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(buf.byteOrder().Uint16(b)), nil
+}
+
+// WriteInt16s writes zero or more int16 values to w.
+func (buf *Buffer) WriteInt16s(w io.Writer, val ...int16) error {
+	order := buf.byteOrder()
+	return writeValues(buf, w, val, 2, func(b []byte, v int16) { order.PutUint16(b, uint16(v)) })
+}
+
+// ReadInt16s reads zero or more int16 values from r, where r reads
+// from a source that has used WriteInt16s to write the int16 values.
+func (buf *Buffer) ReadInt16s(r io.Reader) ([]int16, error) {
+	order := buf.byteOrder()
+	return readValues(buf, r, 2, func(b []byte) int16 { return int16(order.Uint16(b)) })
+}
+
+// WriteInt32 writes an int32 value to w as 4 bytes.
+func (buf *Buffer) WriteInt32(w io.Writer, val int32) error {
+	b := buf.borrow(4)
+	buf.byteOrder().PutUint32(b, uint32(val))
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadInt32 reads an int32 value from r, where r reads from a source
+// that has used WriteInt32 to write an int32 value.
+func (buf *Buffer) ReadInt32(r io.Reader) (int32, error) {
+	b, err := buf.Read(r, 4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(buf.byteOrder().Uint32(b)), nil
+}
+
+// WriteInt32s writes zero or more int32 values to w.
+func (buf *Buffer) WriteInt32s(w io.Writer, val ...int32) error {
+	order := buf.byteOrder()
+	return writeValues(buf, w, val, 4, func(b []byte, v int32) { order.PutUint32(b, uint32(v)) })
+}
+
+// ReadInt32s reads zero or more int32 values from r, where r reads
+// from a source that has used WriteInt32s to write the int32 values.
+func (buf *Buffer) ReadInt32s(r io.Reader) ([]int32, error) {
+	order := buf.byteOrder()
+	return readValues(buf, r, 4, func(b []byte) int32 { return int32(order.Uint32(b)) })
+}
+
+// WriteUint8 writes a uint8 value to w as 1 byte.
+func (buf *Buffer) WriteUint8(w io.Writer, val uint8) error {
+	b := buf.borrow(1)
+	b[0] = val
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadUint8 reads a uint8 value from r, where r reads from a source
+// that has used WriteUint8 to write a uint8 value.
+func (buf *Buffer) ReadUint8(r io.Reader) (uint8, error) {
+	b, err := buf.Read(r, 1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// WriteUint8s writes zero or more uint8 values to w.
+func (buf *Buffer) WriteUint8s(w io.Writer, val ...uint8) error {
+	return writeValues(buf, w, val, 1, func(b []byte, v uint8) { b[0] = v })
+}
+
+// ReadUint8s reads zero or more uint8 values from r, where r reads
+// from a source that has used WriteUint8s to write the uint8 values.
+func (buf *Buffer) ReadUint8s(r io.Reader) ([]uint8, error) {
+	return readValues(buf, r, 1, func(b []byte) uint8 { return b[0] })
+}
+
+// WriteUint16 writes a uint16 value to w as 2 bytes.
+func (buf *Buffer) WriteUint16(w io.Writer, val uint16) error {
+	b := buf.borrow(2)
+	buf.byteOrder().PutUint16(b, val)
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadUint16 reads a uint16 value from r, where r reads from a source
+// that has used WriteUint16 to write a uint16 value.
+func (buf *Buffer) ReadUint16(r io.Reader) (uint16, error) {
+	b, err := buf.Read(r, 2)
+	if err != nil {
+		return 0, err
+	}
+	return buf.byteOrder().Uint16(b), nil
+}
+
+// WriteUint16s writes zero or more uint16 values to w.
+func (buf *Buffer) WriteUint16s(w io.Writer, val ...uint16) error {
+	order := buf.byteOrder()
+	return writeValues(buf, w, val, 2, func(b []byte, v uint16) { order.PutUint16(b, v) })
+}
+
+// ReadUint16s reads zero or more uint16 values from r, where r reads
+// from a source that has used WriteUint16s to write the uint16 values.
+func (buf *Buffer) ReadUint16s(r io.Reader) ([]uint16, error) {
+	order := buf.byteOrder()
+	return readValues(buf, r, 2, func(b []byte) uint16 { return order.Uint16(b) })
+}
+
+// WriteUint32 writes a uint32 value to w as 4 bytes.
+func (buf *Buffer) WriteUint32(w io.Writer, val uint32) error {
+	b := buf.borrow(4)
+	buf.byteOrder().PutUint32(b, val)
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadUint32 reads a uint32 value from r, where r reads from a source
+// that has used WriteUint32 to write a uint32 value.
+func (buf *Buffer) ReadUint32(r io.Reader) (uint32, error) {
+	b, err := buf.Read(r, 4)
+	if err != nil {
+		return 0, err
+	}
+	return buf.byteOrder().Uint32(b), nil
+}
+
+// WriteUint32s writes zero or more uint32 values to w.
+func (buf *Buffer) WriteUint32s(w io.Writer, val ...uint32) error {
+	order := buf.byteOrder()
+	return writeValues(buf, w, val, 4, func(b []byte, v uint32) { order.PutUint32(b, v) })
+}
+
+// ReadUint32s reads zero or more uint32 values from r, where r reads
+// from a source that has used WriteUint32s to write the uint32 values.
+func (buf *Buffer) ReadUint32s(r io.Reader) ([]uint32, error) {
+	order := buf.byteOrder()
+	return readValues(buf, r, 4, func(b []byte) uint32 { return order.Uint32(b) })
+}
+
+// WriteUint64 writes a uint64 value to w as 8 bytes.
+func (buf *Buffer) WriteUint64(w io.Writer, val uint64) error {
+	b := buf.borrow(8)
+	buf.byteOrder().PutUint64(b, val)
+	_, err := w.Write(b)
+	return err
+}
+
+// ReadUint64 reads a uint64 value from r, where r reads from a source
+// that has used WriteUint64 to write a uint64 value.
+func (buf *Buffer) ReadUint64(r io.Reader) (uint64, error) {
+	b, err := buf.Read(r, 8)
+	if err != nil {
+		return 0, err
+	}
+	return buf.byteOrder().Uint64(b), nil
+}
+
+// WriteUint64s writes zero or more uint64 values to w.
+func (buf *Buffer) WriteUint64s(w io.Writer, val ...uint64) error {
+	order := buf.byteOrder()
+	return writeValues(buf, w, val, 8, func(b []byte, v uint64) { order.PutUint64(b, v) })
+}
+
+// ReadUint64s reads zero or more uint64 values from r, where r reads
+// from a source that has used WriteUint64s to write the uint64 values.
+func (buf *Buffer) ReadUint64s(r io.Reader) ([]uint64, error) {
+	order := buf.byteOrder()
+	return readValues(buf, r, 8, func(b []byte) uint64 { return order.Uint64(b) })
+}
@@ -0,0 +1,140 @@
+package bufrw
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// testReadWrite writes each of the values with write and reads them back
+// with read, failing the test if a value does not round-trip.
+func testReadWrite[T any](t *testing.T, values []T, write func(*Buffer, io.Writer, T) error, read func(*Buffer, io.Reader) (T, error)) {
+	t.Helper()
+	for _, value := range values {
+		var buf Buffer
+		var w bytes.Buffer
+		if err := write(&buf, &w, value); err != nil {
+			t.Fatal(err)
+		}
+		got, err := read(&buf, &w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, value) {
+			t.Errorf("Write/Read %v = %v", value, got)
+		}
+		if w.Len() != 0 {
+			t.Errorf("Write/Read %v left %d unread bytes", value, w.Len())
+		}
+	}
+}
+
+func TestBufferReadWriteInt8(t *testing.T) {
+	values := []int8{math.MinInt8, -1, 0, 1, math.MaxInt8}
+	testReadWrite(t, values, (*Buffer).WriteInt8, (*Buffer).ReadInt8)
+	testReadWrite(t, [][]int8{{}, values}, func(buf *Buffer, w io.Writer, val []int8) error {
+		return buf.WriteInt8s(w, val...)
+	}, (*Buffer).ReadInt8s)
+}
+
+func TestBufferReadWriteInt16(t *testing.T) {
+	values := []int16{math.MinInt16, -1, 0, 1, math.MaxInt16}
+	testReadWrite(t, values, (*Buffer).WriteInt16, (*Buffer).ReadInt16)
+	testReadWrite(t, [][]int16{{}, values}, func(buf *Buffer, w io.Writer, val []int16) error {
+		return buf.WriteInt16s(w, val...)
+	}, (*Buffer).ReadInt16s)
+}
+
+func TestBufferReadWriteInt32(t *testing.T) {
+	values := []int32{math.MinInt32, -1, 0, 1, math.MaxInt32}
+	testReadWrite(t, values, (*Buffer).WriteInt32, (*Buffer).ReadInt32)
+	testReadWrite(t, [][]int32{{}, values}, func(buf *Buffer, w io.Writer, val []int32) error {
+		return buf.WriteInt32s(w, val...)
+	}, (*Buffer).ReadInt32s)
+}
+
+func TestBufferReadWriteUint8(t *testing.T) {
+	values := []uint8{0, 1, math.MaxUint8}
+	testReadWrite(t, values, (*Buffer).WriteUint8, (*Buffer).ReadUint8)
+	testReadWrite(t, [][]uint8{{}, values}, func(buf *Buffer, w io.Writer, val []uint8) error {
+		return buf.WriteUint8s(w, val...)
+	}, (*Buffer).ReadUint8s)
+}
+
+func TestBufferReadWriteUint16(t *testing.T) {
+	values := []uint16{0, 1, 8080, math.MaxUint16}
+	testReadWrite(t, values, (*Buffer).WriteUint16, (*Buffer).ReadUint16)
+	testReadWrite(t, [][]uint16{{}, values}, func(buf *Buffer, w io.Writer, val []uint16) error {
+		return buf.WriteUint16s(w, val...)
+	}, (*Buffer).ReadUint16s)
+}
+
+func TestBufferReadWriteUint32(t *testing.T) {
+	values := []uint32{0, 1, math.MaxInt32 + 1, math.MaxUint32}
+	testReadWrite(t, values, (*Buffer).WriteUint32, (*Buffer).ReadUint32)
+	testReadWrite(t, [][]uint32{{}, values}, func(buf *Buffer, w io.Writer, val []uint32) error {
+		return buf.WriteUint32s(w, val...)
+	}, (*Buffer).ReadUint32s)
+}
+
+func TestBufferReadWriteUint64(t *testing.T) {
+	values := []uint64{0, 1, math.MaxInt64 + 1, math.MaxUint64}
+	testReadWrite(t, values, (*Buffer).WriteUint64, (*Buffer).ReadUint64)
+	testReadWrite(t, [][]uint64{{}, values}, func(buf *Buffer, w io.Writer, val []uint64) error {
+		return buf.WriteUint64s(w, val...)
+	}, (*Buffer).ReadUint64s)
+}
+
+func TestBufferIntegerWidths(t *testing.T) {
+	tests := []struct {
+		write func(*Buffer, io.Writer) error
+		want  []byte
+	}{
+		{func(buf *Buffer, w io.Writer) error { return buf.WriteInt8(w, -2) }, []byte{0xfe}},
+		{func(buf *Buffer, w io.Writer) error { return buf.WriteInt16(w, -2) }, []byte{0xff, 0xfe}},
+		{func(buf *Buffer, w io.Writer) error { return buf.WriteInt32(w, -2) }, []byte{0xff, 0xff, 0xff, 0xfe}},
+		{func(buf *Buffer, w io.Writer) error { return buf.WriteUint16(w, 0x1234) }, []byte{0x12, 0x34}},
+		{func(buf *Buffer, w io.Writer) error { return buf.WriteUint32(w, 0x12345678) }, []byte{0x12, 0x34, 0x56, 0x78}},
+		{func(buf *Buffer, w io.Writer) error { return buf.WriteUint64(w, math.MaxUint64) }, bytes.Repeat([]byte{0xff}, 8)},
+	}
+	for i, test := range tests {
+		var buf Buffer
+		var w bytes.Buffer
+		if err := test.write(&buf, &w); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(w.Bytes(), test.want) {
+			t.Errorf("test %d: wrote %x, want %x", i, w.Bytes(), test.want)
+		}
+	}
+}
+
+func BenchmarkInt8s(b *testing.B) {
+	benchmarkPlural(b, make([]int8, 64*1024), 1, (*Buffer).WriteInt8s, (*Buffer).ReadInt8s)
+}
+
+func BenchmarkInt16s(b *testing.B) {
+	benchmarkPlural(b, make([]int16, 64*1024), 2, (*Buffer).WriteInt16s, (*Buffer).ReadInt16s)
+}
+
+func BenchmarkInt32s(b *testing.B) {
+	benchmarkPlural(b, make([]int32, 64*1024), 4, (*Buffer).WriteInt32s, (*Buffer).ReadInt32s)
+}
+
+func BenchmarkUint8s(b *testing.B) {
+	benchmarkPlural(b, make([]uint8, 64*1024), 1, (*Buffer).WriteUint8s, (*Buffer).ReadUint8s)
+}
+
+func BenchmarkUint16s(b *testing.B) {
+	benchmarkPlural(b, make([]uint16, 64*1024), 2, (*Buffer).WriteUint16s, (*Buffer).ReadUint16s)
+}
+
+func BenchmarkUint32s(b *testing.B) {
+	benchmarkPlural(b, make([]uint32, 64*1024), 4, (*Buffer).WriteUint32s, (*Buffer).ReadUint32s)
+}
+
+func BenchmarkUint64s(b *testing.B) {
+	benchmarkPlural(b, make([]uint64, 64*1024), 8, (*Buffer).WriteUint64s, (*Buffer).ReadUint64s)
+}
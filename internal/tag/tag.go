@@ -0,0 +1,59 @@
+// Package tag parses the bufrw struct tags shared by bufrw.Marshal and the
+// bufrwgen code generator, so that both accept the same options.
+package tag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Widths of integer fields given by the varint and fixed32 options. The
+// fixed option, the default, is the empty width.
+const (
+	Varint  = "varint"
+	Fixed32 = "fixed32"
+)
+
+// Options holds the options of a struct field given by its bufrw struct
+// tag. See bufrw.Marshal for the available options.
+type Options struct {
+	Skip     bool
+	Width    string
+	Packed   bool
+	Max      int
+	Optional bool
+}
+
+// Parse parses the bufrw struct tag of a field.
+func Parse(tag string) (Options, error) {
+	var opts Options
+	if tag == "" {
+		return opts, nil
+	}
+	if tag == "-" {
+		opts.Skip = true
+		return opts, nil
+	}
+	for _, opt := range strings.Split(tag, ",") {
+		switch opt = strings.TrimSpace(opt); {
+		case opt == "fixed":
+			opts.Width = ""
+		case opt == Varint, opt == Fixed32:
+			opts.Width = opt
+		case opt == "packed":
+			opts.Packed = true
+		case opt == "optional":
+			opts.Optional = true
+		case strings.HasPrefix(opt, "max="):
+			max, err := strconv.Atoi(strings.TrimPrefix(opt, "max="))
+			if err != nil || max <= 0 {
+				return opts, fmt.Errorf("invalid tag option %q", opt)
+			}
+			opts.Max = max
+		default:
+			return opts, fmt.Errorf("unknown tag option %q", opt)
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,28 @@
+package tag
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want Options
+	}{
+		{"", Options{}},
+		{"-", Options{Skip: true}},
+		{"varint", Options{Width: Varint}},
+		{"varint,fixed", Options{}},
+		{"fixed32, optional", Options{Width: Fixed32, Optional: true}},
+		{"packed,max=8", Options{Packed: true, Max: 8}},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.tag)
+		if err != nil || got != test.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", test.tag, got, err, test.want)
+		}
+	}
+	for _, tag := range []string{"max=0", "max=x", "bogus", "varint,"} {
+		if _, err := Parse(tag); err == nil {
+			t.Errorf("Parse(%q) succeeded", tag)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package bufrw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrLengthExceeded is returned when a length read from the input
+	// exceeds the limits configured on the buffer.
+	ErrLengthExceeded = errors.New("bufrw: length exceeds limit")
+
+	// ErrNegativeLength is returned when a length read from the input
+	// is negative.
+	ErrNegativeLength = errors.New("bufrw: negative length")
+
+	// ErrDepthExceeded is returned when nested values read from the input
+	// exceed the maximum nesting depth configured on the buffer.
+	ErrDepthExceeded = errors.New("bufrw: nesting depth exceeds limit")
+)
+
+// Limits restricts the sizes of the values a Buffer reads, protecting
+// against corrupt or hostile input requesting huge allocations. A field
+// of zero or less means no limit.
+type Limits struct {
+	// MaxStringLen is the maximum length in bytes of a string.
+	MaxStringLen int
+
+	// MaxSliceLen is the maximum number of elements in a slice.
+	MaxSliceLen int
+
+	// MaxMessageBytes is the maximum number of bytes read by a single
+	// Reader. It is only enforced when reading through a Reader.
+	MaxMessageBytes int64
+
+	// MaxDepth is the maximum nesting depth of serializable values, and of
+	// the pointers, structs, slices, arrays and maps read by Unmarshal.
+	MaxDepth int
+}
+
+// SetLimits sets the limits the buffer enforces when reading.
+func (buf *Buffer) SetLimits(limits Limits) {
+	buf.limits = limits
+}
+
+// Limits returns the limits the buffer enforces when reading.
+func (buf *Buffer) Limits() Limits {
+	return buf.limits
+}
+
+// readLen reads a length prefix from r and validates it against max and
+// the number of bytes remaining in the message, assuming each element
+// occupies at least elemSize bytes.
+func (buf *Buffer) readLen(r io.Reader, max, elemSize int) (int, error) {
+	n, err := buf.readNullableLen(r, max, elemSize)
+	if err == nil && n == nilLen {
+		return 0, fmt.Errorf("bufrw: unexpected nil length: %w", ErrNegativeLength)
+	}
+	return n, err
+}
+
+// readNullableLen is like readLen, but returns nilLen if the length prefix
+// marks a nil value.
+func (buf *Buffer) readNullableLen(r io.Reader, max, elemSize int) (int, error) {
+	n, err := buf.readLenPrefix(r)
+	if err != nil {
+		return 0, err
+	}
+	if n == nilLen {
+		return nilLen, nil
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("bufrw: length %d: %w", n, ErrNegativeLength)
+	}
+	if err := checkLen(n, max); err != nil {
+		return 0, err
+	}
+	if err := checkRemaining(r, int64(n)*int64(elemSize)); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// checkRemaining fails with ErrLengthExceeded if r is limited to a message
+// with fewer than n bytes remaining.
+func checkRemaining(r io.Reader, n int64) error {
+	if cr, ok := r.(*countingReader); ok && cr.max > 0 {
+		if remaining := cr.max - cr.n; n > remaining {
+			return fmt.Errorf("bufrw: %d bytes exceed remaining %d bytes: %w", n, remaining, ErrLengthExceeded)
+		}
+	}
+	return nil
+}
+
+// checkLen fails with ErrLengthExceeded if n exceeds max. A max of zero or
+// less means no limit.
+func checkLen(n, max int) error {
+	if max > 0 && n > max {
+		return fmt.Errorf("bufrw: length %d exceeds %d: %w", n, max, ErrLengthExceeded)
+	}
+	return nil
+}
+
+// minLimit returns the stricter of the limits a and b, where a limit of
+// zero or less means no limit.
+func minLimit(a, b int) int {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// enter increments the nesting depth of the buffer, failing if it
+// exceeds the configured maximum. Each successful call must be paired
+// with a call to leave.
+func (buf *Buffer) enter() error {
+	if max := buf.limits.MaxDepth; max > 0 && buf.depth >= max {
+		return fmt.Errorf("bufrw: depth %d: %w", buf.depth+1, ErrDepthExceeded)
+	}
+	buf.depth++
+	return nil
+}
+
+// leave decrements the nesting depth of the buffer.
+func (buf *Buffer) leave() {
+	buf.depth--
+}
+
+// countingReader counts the bytes read from r, failing with
+// ErrLengthExceeded once more than max bytes are requested.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	if cr.max > 0 {
+		remaining := cr.max - cr.n
+		if remaining <= 0 {
+			return 0, fmt.Errorf("bufrw: message exceeds %d bytes: %w", cr.max, ErrLengthExceeded)
+		}
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,169 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestBufferLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		write  func(buf *Buffer, w io.Writer) error
+		read   func(buf *Buffer, r io.Reader) error
+		want   error
+	}{
+		{
+			name:   "string within limit",
+			limits: Limits{MaxStringLen: 3},
+			write:  func(buf *Buffer, w io.Writer) error { return buf.WriteString(w, "abc") },
+			read:   func(buf *Buffer, r io.Reader) error { _, err := buf.ReadString(r); return err },
+		},
+		{
+			name:   "string exceeds limit",
+			limits: Limits{MaxStringLen: 3},
+			write:  func(buf *Buffer, w io.Writer) error { return buf.WriteString(w, "abcd") },
+			read:   func(buf *Buffer, r io.Reader) error { _, err := buf.ReadString(r); return err },
+			want:   ErrLengthExceeded,
+		},
+		{
+			name:   "slice exceeds limit",
+			limits: Limits{MaxSliceLen: 2},
+			write:  func(buf *Buffer, w io.Writer) error { return buf.WriteInts(w, 1, 2, 3) },
+			read:   func(buf *Buffer, r io.Reader) error { _, err := buf.ReadInts(r); return err },
+			want:   ErrLengthExceeded,
+		},
+		{
+			name:   "byte values exceed limit",
+			limits: Limits{MaxSliceLen: 2},
+			write:  func(buf *Buffer, w io.Writer) error { return buf.WriteByteValues(w, 1, 2, 3) },
+			read:   func(buf *Buffer, r io.Reader) error { _, err := buf.ReadByteValues(r); return err },
+			want:   ErrLengthExceeded,
+		},
+		{
+			name:  "negative length",
+			write: func(buf *Buffer, w io.Writer) error { return buf.WriteInt(w, -5) },
+			read:  func(buf *Buffer, r io.Reader) error { _, err := buf.ReadStrings(r); return err },
+			want:  ErrNegativeLength,
+		},
+		{
+			name:  "negative string length",
+			write: func(buf *Buffer, w io.Writer) error { return buf.WriteInt(w, -5) },
+			read:  func(buf *Buffer, r io.Reader) error { _, err := buf.ReadString(r); return err },
+			want:  ErrNegativeLength,
+		},
+	}
+	for _, test := range tests {
+		var buf Buffer
+		var w bytes.Buffer
+		if err := test.write(&buf, &w); err != nil {
+			t.Fatal(err)
+		}
+		buf.SetLimits(test.limits)
+		if err := test.read(&buf, &w); !errors.Is(err, test.want) {
+			t.Errorf("%s: got error %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestReaderMaxMessageBytes(t *testing.T) {
+	var buf Buffer
+	var w bytes.Buffer
+	if err := buf.WriteInt(&w, 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	buf.SetLimits(Limits{MaxMessageBytes: 64})
+	if _, err := buf.Reader(&w).ReadByteValues(); !errors.Is(err, ErrLengthExceeded) {
+		t.Errorf("ReadByteValues with huge length: got error %v, want %v", err, ErrLengthExceeded)
+	}
+
+	w.Reset()
+	for i := 0; i < 3; i++ {
+		if err := buf.WriteInt64(&w, int64(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf.SetLimits(Limits{MaxMessageBytes: 20})
+	r := buf.Reader(&w)
+	for i := 0; i < 2; i++ {
+		if _, err := r.ReadInt64(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := r.ReadInt64(); !errors.Is(err, ErrLengthExceeded) {
+		t.Errorf("ReadInt64 beyond message: got error %v, want %v", err, ErrLengthExceeded)
+	}
+}
+
+func TestReaderMaxMessageBytesStrings(t *testing.T) {
+	for _, varint := range []bool{false, true} {
+		var buf Buffer
+		buf.SetVarintLengths(varint)
+		var w bytes.Buffer
+		// A length of 10 strings followed by only 5 empty strings.
+		if err := buf.WriteLen(&w, 10); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			if err := buf.WriteString(&w, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+		rest := 5 * buf.lenSize()
+		buf.SetLimits(Limits{MaxMessageBytes: int64(w.Len())})
+		if _, err := buf.Reader(&w).ReadStrings(); !errors.Is(err, ErrLengthExceeded) {
+			t.Errorf("ReadStrings beyond message: got error %v, want %v", err, ErrLengthExceeded)
+		}
+		// The length is rejected before reading any of the strings.
+		if w.Len() != rest {
+			t.Errorf("ReadStrings beyond message read %d bytes of the strings", rest-w.Len())
+		}
+	}
+}
+
+type nested struct {
+	child *nested
+}
+
+func (n *nested) Serialize() ([]byte, error) { return nil, errors.New("not implemented") }
+func (n *nested) Deserialize([]byte) error   { return errors.New("not implemented") }
+
+func (n *nested) SerializeToBufRW(w io.Writer, buf *Buffer) error {
+	if err := buf.WriteBool(w, n.child != nil); err != nil || n.child == nil {
+		return err
+	}
+	return buf.WriteSerializable(w, n.child)
+}
+
+func (n *nested) DeserializeFromBufRW(r io.Reader, buf *Buffer) error {
+	ok, err := buf.ReadBool(r)
+	if err != nil || !ok {
+		return err
+	}
+	n.child = &nested{}
+	return buf.ReadSerializable(r, n.child)
+}
+
+func TestBufferMaxDepth(t *testing.T) {
+	value := &nested{&nested{&nested{&nested{}}}}
+	var buf Buffer
+	var w bytes.Buffer
+	if err := buf.WriteSerializable(&w, value); err != nil {
+		t.Fatal(err)
+	}
+	b := w.Bytes()
+
+	buf.SetLimits(Limits{MaxDepth: 4})
+	if err := buf.ReadSerializable(bytes.NewReader(b), &nested{}); err != nil {
+		t.Errorf("ReadSerializable at max depth: %v", err)
+	}
+	buf.SetLimits(Limits{MaxDepth: 3})
+	if err := buf.ReadSerializable(bytes.NewReader(b), &nested{}); !errors.Is(err, ErrDepthExceeded) {
+		t.Errorf("ReadSerializable beyond max depth: got error %v, want %v", err, ErrDepthExceeded)
+	}
+	if buf.depth != 0 {
+		t.Errorf("depth after ReadSerializable = %d, want 0", buf.depth)
+	}
+}
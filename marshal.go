@@ -0,0 +1,631 @@
+package bufrw
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/snechholt/bufrw/internal/tag"
+)
+
+// UnsupportedTypeError is returned by Marshal and Unmarshal when asked to
+// encode or decode a value of a type they do not support.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "bufrw: unsupported type " + e.Type.String()
+}
+
+// Marshal writes v to w using a new buffer. See Buffer.Marshal.
+func Marshal(w io.Writer, v any) error {
+	var buf Buffer
+	return buf.Marshal(w, v)
+}
+
+// Unmarshal reads a value from r into the value pointed to by v using a
+// new buffer. See Buffer.Unmarshal.
+func Unmarshal(r io.Reader, v any) error {
+	var buf Buffer
+	return buf.Unmarshal(r, v)
+}
+
+// Marshal writes v, or the value v points to, to w using reflection.
+//
+// Values are encoded with the methods of the buffer: booleans, integers,
+// floats, complex numbers and strings with the method for their type,
+// where int and int64 use WriteInt64 and uint, uint64 and uintptr use
+// WriteUint64. Byte slices are written with WriteByteValues. Other slices
+// and maps are written as their length followed by their elements, where
+// map entries are sorted by key for keys of basic types. Nil slices and
+// maps are written as nil if the buffer is nullable; see SetNullable.
+// Arrays are written as their elements. Pointers are written as a boolean
+// telling whether the pointer is nil, followed by the value pointed to.
+// Times are written with WriteTime, and structs as their exported fields
+// in order. Values implementing Serializable, either directly or through
+// a pointer receiver, are written with WriteSerializable, and other
+// values implementing the marshaler and unmarshaler interfaces of package
+// encoding, in binary or text form, are written with WriteMarshaler.
+//
+// The encoding of a struct field can be changed with a bufrw struct tag
+// holding a comma-separated list of options, or "-" to skip the field:
+//
+//	fixed     encode integers with their fixed-width method (the default)
+//	varint    encode integers with WriteVarint or WriteUvarint
+//	fixed32   encode integers as 4 bytes with WriteInt or WriteUint32
+//	packed    encode a bool slice with WritePackedBools
+//	max=N     limit a string, slice or map to N bytes or elements
+//	optional  precede the field by a boolean telling whether it is set,
+//	          omitting the value if it is the zero value
+//
+// Integer width options also apply to the elements of slices, arrays and
+// maps, and to the values pointers point to.
+//
+// The encoding plan of each type is computed once and cached.
+func (buf *Buffer) Marshal(w io.Writer, v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return errors.New("bufrw: Marshal of nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return errors.New("bufrw: Marshal of nil value")
+	}
+	c, err := codecFor(rv.Type())
+	if err != nil {
+		return err
+	}
+	return c.enc(buf, w, rv)
+}
+
+// Unmarshal reads a value from r into the value pointed to by v, where r
+// reads from a source that has used Marshal to write a value of the same
+// type. See Marshal for the encoding.
+func (buf *Buffer) Unmarshal(r io.Reader, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("bufrw: Unmarshal requires a non-nil pointer")
+	}
+	c, err := codecFor(rv.Type().Elem())
+	if err != nil {
+		return err
+	}
+	return c.dec(buf, r, rv.Elem())
+}
+
+type encoder func(buf *Buffer, w io.Writer, v reflect.Value) error
+
+type decoder func(buf *Buffer, r io.Reader, v reflect.Value) error
+
+// limitDepth wraps the decoder of a pointer, struct, slice, array or map
+// type, counting each value it decodes as a level of nesting against the
+// maximum depth of the buffer.
+func limitDepth(dec decoder) decoder {
+	return func(buf *Buffer, r io.Reader, v reflect.Value) error {
+		if err := buf.enter(); err != nil {
+			return err
+		}
+		defer buf.leave()
+		return dec(buf, r, v)
+	}
+}
+
+// codec holds the encoding plan of a type.
+type codec struct {
+	enc encoder
+	dec decoder
+
+	// minSize is the minimum size of an encoded value, checked against the
+	// bytes remaining in the message before decoding a length of values.
+	minSize int
+}
+
+var (
+	codecs  sync.Map // map[reflect.Type]*codec
+	codecMu sync.Mutex
+
+	serializableType      = reflect.TypeOf((*Serializable)(nil)).Elem()
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	textMarshalerType     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType              = reflect.TypeOf(time.Time{})
+)
+
+// codecFor returns the cached codec of t, building it if needed.
+func codecFor(t reflect.Type) (*codec, error) {
+	if c, ok := codecs.Load(t); ok {
+		return c.(*codec), nil
+	}
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	building := make(map[reflect.Type]*codec)
+	c, err := buildCodec(t, building)
+	if err != nil {
+		return nil, err
+	}
+	for t, c := range building {
+		codecs.Store(t, c)
+	}
+	return c, nil
+}
+
+// buildCodec builds the codec of t. Codecs under construction are kept in
+// building, so that recursive types refer to the codec being built.
+func buildCodec(t reflect.Type, building map[reflect.Type]*codec) (*codec, error) {
+	if c, ok := codecs.Load(t); ok {
+		return c.(*codec), nil
+	}
+	if c, ok := building[t]; ok {
+		return c, nil
+	}
+	// All but structs and arrays, which set their own, encode at least a
+	// byte.
+	c := &codec{minSize: 1}
+	building[t] = c
+	var err error
+	switch {
+	case t == timeType:
+		c.enc, c.dec = encodeTime, decodeTime
+	case t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(serializableType):
+		c.enc, c.dec = encodeSerializable, decodeSerializable
+	case t.Kind() != reflect.Pointer && isMarshaler(reflect.PointerTo(t)):
+		c.enc, c.dec = encodeMarshaler, decodeMarshaler
+	case t.Kind() == reflect.Pointer:
+		err = buildPointerCodec(c, t, building)
+	case t.Kind() == reflect.Struct:
+		err = buildStructCodec(c, t, building)
+	case t.Kind() == reflect.Slice:
+		err = buildSliceCodec(c, t, building)
+	case t.Kind() == reflect.Array:
+		err = buildArrayCodec(c, t, building)
+	case t.Kind() == reflect.Map:
+		err = buildMapCodec(c, t, building)
+	default:
+		err = buildBasicCodec(c, t)
+	}
+	if err != nil {
+		delete(building, t)
+		return nil, err
+	}
+	return c, nil
+}
+
+func buildBasicCodec(c *codec, t reflect.Type) error {
+	switch t.Kind() {
+	case reflect.Bool:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteBool(w, v.Bool()) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadBool(r)
+			v.SetBool(val)
+			return err
+		}
+	case reflect.Int8:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteInt8(w, int8(v.Int())) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadInt8(r)
+			v.SetInt(int64(val))
+			return err
+		}
+	case reflect.Int16:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteInt16(w, int16(v.Int())) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadInt16(r)
+			v.SetInt(int64(val))
+			return err
+		}
+	case reflect.Int32:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteInt32(w, int32(v.Int())) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadInt32(r)
+			v.SetInt(int64(val))
+			return err
+		}
+	case reflect.Int, reflect.Int64:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteInt64(w, v.Int()) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadInt64(r)
+			v.SetInt(val)
+			return err
+		}
+	case reflect.Uint8:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteUint8(w, uint8(v.Uint())) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadUint8(r)
+			v.SetUint(uint64(val))
+			return err
+		}
+	case reflect.Uint16:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteUint16(w, uint16(v.Uint())) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadUint16(r)
+			v.SetUint(uint64(val))
+			return err
+		}
+	case reflect.Uint32:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteUint32(w, uint32(v.Uint())) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadUint32(r)
+			v.SetUint(uint64(val))
+			return err
+		}
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteUint64(w, v.Uint()) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadUint64(r)
+			v.SetUint(val)
+			return err
+		}
+	case reflect.Float32:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteFloat32(w, float32(v.Float())) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadFloat32(r)
+			v.SetFloat(float64(val))
+			return err
+		}
+	case reflect.Float64:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteFloat64(w, v.Float()) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadFloat64(r)
+			v.SetFloat(val)
+			return err
+		}
+	case reflect.Complex64:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error {
+			return buf.WriteComplex64(w, complex64(v.Complex()))
+		}
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadComplex64(r)
+			v.SetComplex(complex128(val))
+			return err
+		}
+	case reflect.Complex128:
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteComplex128(w, v.Complex()) }
+		c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			val, err := buf.ReadComplex128(r)
+			v.SetComplex(val)
+			return err
+		}
+	case reflect.String:
+		setStringCodec(c, 0)
+	default:
+		return &UnsupportedTypeError{t}
+	}
+	return nil
+}
+
+// setStringCodec sets c to encode strings of at most max bytes.
+func setStringCodec(c *codec, max int) {
+	c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error {
+		if err := checkLen(v.Len(), max); err != nil {
+			return err
+		}
+		return buf.WriteString(w, v.String())
+	}
+	c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+		val, err := buf.readString(r, minLimit(max, buf.limits.MaxStringLen))
+		v.SetString(val)
+		return err
+	}
+}
+
+func encodeSerializable(buf *Buffer, w io.Writer, v reflect.Value) error {
+	if !v.CanAddr() {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p.Elem()
+	}
+	return buf.WriteSerializable(w, v.Addr().Interface().(Serializable))
+}
+
+func decodeSerializable(buf *Buffer, r io.Reader, v reflect.Value) error {
+	return buf.ReadSerializable(r, v.Addr().Interface().(Serializable))
+}
+
+// isMarshaler reports whether t implements encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler, or encoding.TextMarshaler and
+// encoding.TextUnmarshaler.
+func isMarshaler(t reflect.Type) bool {
+	return (t.Implements(binaryMarshalerType) && t.Implements(binaryUnmarshalerType)) ||
+		(t.Implements(textMarshalerType) && t.Implements(textUnmarshalerType))
+}
+
+func encodeMarshaler(buf *Buffer, w io.Writer, v reflect.Value) error {
+	if !v.CanAddr() {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p.Elem()
+	}
+	return buf.WriteMarshaler(w, v.Addr().Interface())
+}
+
+func decodeMarshaler(buf *Buffer, r io.Reader, v reflect.Value) error {
+	return buf.ReadMarshaler(r, v.Addr().Interface())
+}
+
+func encodeTime(buf *Buffer, w io.Writer, v reflect.Value) error {
+	return buf.WriteTime(w, v.Interface().(time.Time))
+}
+
+func decodeTime(buf *Buffer, r io.Reader, v reflect.Value) error {
+	val, err := buf.ReadTime(r)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(val))
+	return nil
+}
+
+func buildPointerCodec(c *codec, t reflect.Type, building map[reflect.Type]*codec) error {
+	elem, err := buildCodec(t.Elem(), building)
+	if err != nil {
+		return err
+	}
+	setPointerCodec(c, t, elem)
+	return nil
+}
+
+func setPointerCodec(c *codec, t reflect.Type, elem *codec) {
+	c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error {
+		if err := buf.WriteBool(w, !v.IsNil()); err != nil || v.IsNil() {
+			return err
+		}
+		return elem.enc(buf, w, v.Elem())
+	}
+	c.dec = limitDepth(func(buf *Buffer, r io.Reader, v reflect.Value) error {
+		ok, err := buf.ReadBool(r)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return elem.dec(buf, r, v.Elem())
+	})
+}
+
+func buildStructCodec(c *codec, t reflect.Type, building map[reflect.Type]*codec) error {
+	type field struct {
+		index int
+		codec *codec
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		opts, err := tag.Parse(f.Tag.Get("bufrw"))
+		if err != nil {
+			return fmt.Errorf("bufrw: field %s.%s: %w", t, f.Name, err)
+		}
+		if opts.Skip {
+			continue
+		}
+		fc, err := buildFieldCodec(f.Type, opts, building)
+		if err != nil {
+			return fmt.Errorf("bufrw: field %s.%s: %w", t, f.Name, err)
+		}
+		fields = append(fields, field{index: i, codec: fc})
+	}
+	c.minSize = 0
+	for _, f := range fields {
+		c.minSize += f.codec.minSize
+	}
+	c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error {
+		for _, f := range fields {
+			if err := f.codec.enc(buf, w, v.Field(f.index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	c.dec = limitDepth(func(buf *Buffer, r io.Reader, v reflect.Value) error {
+		for _, f := range fields {
+			if err := f.codec.dec(buf, r, v.Field(f.index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+func buildSliceCodec(c *codec, t reflect.Type, building map[reflect.Type]*codec) error {
+	if t.Elem().Kind() == reflect.Uint8 {
+		setBytesCodec(c, 0)
+		return nil
+	}
+	elem, err := buildCodec(t.Elem(), building)
+	if err != nil {
+		return err
+	}
+	setSliceCodec(c, t, elem, 0)
+	return nil
+}
+
+// setBytesCodec sets c to encode byte slices of at most max bytes.
+func setBytesCodec(c *codec, max int) {
+	c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error {
+		if err := checkLen(v.Len(), max); err != nil {
+			return err
+		}
+		return buf.WriteByteValues(w, v.Bytes()...)
+	}
+	c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+		n, err := buf.readNullableLen(r, minLimit(max, buf.limits.MaxSliceLen), 1)
+		if err != nil {
+			return err
+		}
+		if n == nilLen {
+			v.SetBytes(nil)
+			return nil
+		}
+		val := make([]byte, n)
+		if _, err := io.ReadFull(r, val); err != nil {
+			return err
+		}
+		v.SetBytes(val)
+		return nil
+	}
+}
+
+// setSliceCodec sets c to encode slices of type t of at most max elements.
+func setSliceCodec(c *codec, t reflect.Type, elem *codec, max int) {
+	c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error {
+		n := v.Len()
+		if err := checkLen(n, max); err != nil {
+			return err
+		}
+		if err := buf.WriteNullableLen(w, n, v.IsNil()); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := elem.enc(buf, w, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	c.dec = limitDepth(func(buf *Buffer, r io.Reader, v reflect.Value) error {
+		n, err := buf.readNullableLen(r, minLimit(max, buf.limits.MaxSliceLen), elem.minSize)
+		if err != nil {
+			return err
+		}
+		if n == nilLen {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		s := reflect.MakeSlice(t, n, n)
+		for i := 0; i < n; i++ {
+			if err := elem.dec(buf, r, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	})
+}
+
+func buildArrayCodec(c *codec, t reflect.Type, building map[reflect.Type]*codec) error {
+	elem, err := buildCodec(t.Elem(), building)
+	if err != nil {
+		return err
+	}
+	setArrayCodec(c, t, elem)
+	return nil
+}
+
+func setArrayCodec(c *codec, t reflect.Type, elem *codec) {
+	n := t.Len()
+	c.minSize = n * elem.minSize
+	c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error {
+		for i := 0; i < n; i++ {
+			if err := elem.enc(buf, w, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	c.dec = limitDepth(func(buf *Buffer, r io.Reader, v reflect.Value) error {
+		for i := 0; i < n; i++ {
+			if err := elem.dec(buf, r, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func buildMapCodec(c *codec, t reflect.Type, building map[reflect.Type]*codec) error {
+	key, err := buildCodec(t.Key(), building)
+	if err != nil {
+		return err
+	}
+	elem, err := buildCodec(t.Elem(), building)
+	if err != nil {
+		return err
+	}
+	setMapCodec(c, t, key, elem, 0)
+	return nil
+}
+
+// setMapCodec sets c to encode maps of type t of at most max entries.
+func setMapCodec(c *codec, t reflect.Type, key, elem *codec, max int) {
+	less := keyLess(t.Key())
+	c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error {
+		if err := checkLen(v.Len(), max); err != nil {
+			return err
+		}
+		if err := buf.WriteNullableLen(w, v.Len(), v.IsNil()); err != nil {
+			return err
+		}
+		keys := v.MapKeys()
+		if less != nil {
+			sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+		}
+		for _, k := range keys {
+			if err := key.enc(buf, w, k); err != nil {
+				return err
+			}
+			if err := elem.enc(buf, w, v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	c.dec = limitDepth(func(buf *Buffer, r io.Reader, v reflect.Value) error {
+		n, err := buf.readNullableLen(r, minLimit(max, buf.limits.MaxSliceLen), key.minSize+elem.minSize)
+		if err != nil {
+			return err
+		}
+		if n == nilLen {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		m := reflect.MakeMapWithSize(t, n)
+		for i := 0; i < n; i++ {
+			k := reflect.New(t.Key()).Elem()
+			if err := key.dec(buf, r, k); err != nil {
+				return err
+			}
+			e := reflect.New(t.Elem()).Elem()
+			if err := elem.dec(buf, r, e); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, e)
+		}
+		v.Set(m)
+		return nil
+	})
+}
+
+// keyLess returns a function ordering map keys of type t, or nil if keys
+// of type t are not ordered.
+func keyLess(t reflect.Type) func(a, b reflect.Value) bool {
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(a, b reflect.Value) bool { return !a.Bool() && b.Bool() }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(a, b reflect.Value) bool { return a.Int() < b.Int() }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(a, b reflect.Value) bool { return a.Uint() < b.Uint() }
+	case reflect.Float32, reflect.Float64:
+		return func(a, b reflect.Value) bool { return a.Float() < b.Float() }
+	case reflect.String:
+		return func(a, b reflect.Value) bool { return a.String() < b.String() }
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type marshalPoint struct {
+	X, Y int32
+}
+
+type marshalNode struct {
+	Name     string
+	Children []*marshalNode
+}
+
+type marshalAll struct {
+	Bool       bool
+	Int        int
+	Int8       int8
+	Int16      int16
+	Int32      int32
+	Int64      int64
+	Uint       uint
+	Uint8      uint8
+	Uint16     uint16
+	Uint32     uint32
+	Uint64     uint64
+	Float32    float32
+	Float64    float64
+	String     string
+	Bytes      []byte
+	Strings    []string
+	Array      [3]int16
+	Map        map[string][]int64
+	Points     map[int]marshalPoint
+	Pointer    *marshalPoint
+	NilPointer *marshalPoint
+	Tree       marshalNode
+	Custom     serializableString
+	unexported int
+}
+
+// serializableString implements Serializable by writing its value in
+// upper case.
+type serializableString struct {
+	value string
+}
+
+func (s *serializableString) Serialize() ([]byte, error) {
+	return bytes.ToUpper([]byte(s.value)), nil
+}
+
+func (s *serializableString) Deserialize(b []byte) error {
+	s.value = string(b)
+	return nil
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	value := marshalAll{
+		Bool:    true,
+		Int:     -1 << 40,
+		Int8:    math.MinInt8,
+		Int16:   math.MinInt16,
+		Int32:   math.MinInt32,
+		Int64:   math.MinInt64,
+		Uint:    1 << 63,
+		Uint8:   math.MaxUint8,
+		Uint16:  math.MaxUint16,
+		Uint32:  math.MaxUint32,
+		Uint64:  math.MaxUint64,
+		Float32: 1.5,
+		Float64: -2.25,
+		String:  "ㄒ乇丂ㄒ",
+		Bytes:   []byte{1, 2, 3},
+		Strings: []string{"a", "", "b"},
+		Array:   [3]int16{1, -2, 3},
+		Map:     map[string][]int64{"a": {1, 2}, "b": {}, "c": {math.MaxInt64}},
+		Points:  map[int]marshalPoint{1: {1, 2}, -5: {3, 4}},
+		Pointer: &marshalPoint{5, 6},
+		Tree: marshalNode{
+			Name: "root",
+			Children: []*marshalNode{
+				{Name: "a", Children: []*marshalNode{}},
+				{Name: "b", Children: []*marshalNode{{Name: "c", Children: []*marshalNode{}}}},
+			},
+		},
+		Custom:     serializableString{"abc"},
+		unexported: 42,
+	}
+	var w bytes.Buffer
+	if err := Marshal(&w, &value); err != nil {
+		t.Fatal(err)
+	}
+	var got marshalAll
+	if err := Unmarshal(&w, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := value
+	want.Custom = serializableString{"ABC"}
+	want.unexported = 0
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Marshal/Unmarshal\n got  %+v\n want %+v", got, want)
+	}
+	if w.Len() != 0 {
+		t.Errorf("%d unread bytes", w.Len())
+	}
+}
+
+func TestMarshalEncoding(t *testing.T) {
+	value := struct {
+		A int
+		B string
+		C map[string]bool
+		D *int32
+	}{A: 7, B: "x", C: map[string]bool{"b": true, "a": false}}
+
+	var buf Buffer
+	var want bytes.Buffer
+	buf.WriteInt64(&want, 7)
+	buf.WriteString(&want, "x")
+	buf.WriteInt(&want, 2)
+	buf.WriteString(&want, "a")
+	buf.WriteBool(&want, false)
+	buf.WriteString(&want, "b")
+	buf.WriteBool(&want, true)
+	buf.WriteBool(&want, false)
+
+	for i := 0; i < 10; i++ {
+		var got bytes.Buffer
+		if err := buf.Marshal(&got, value); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Fatalf("Marshal = %x, want %x", got.Bytes(), want.Bytes())
+		}
+	}
+}
+
+func TestMarshalUnsupported(t *testing.T) {
+	var target *UnsupportedTypeError
+	if err := Marshal(io.Discard, struct{ C chan int }{}); !errors.As(err, &target) {
+		t.Errorf("Marshal of chan field: got error %v, want *UnsupportedTypeError", err)
+	}
+	if err := Unmarshal(bytes.NewReader(nil), struct{}{}); err == nil {
+		t.Error("Unmarshal into non-pointer: expected error")
+	}
+}
+
+func TestUnmarshalMaxDepth(t *testing.T) {
+	type list struct {
+		Next *list
+	}
+	type tree struct {
+		C []tree
+	}
+	type graph struct {
+		M map[string]graph
+	}
+	tests := []struct {
+		value, target any
+	}{
+		{&list{&list{&list{}}}, &list{}},
+		{&tree{[]tree{{[]tree{{}}}}}, &tree{}},
+		{&graph{map[string]graph{"a": {map[string]graph{"b": {}}}}}, &graph{}},
+		{&[1][1][1]int{}, &[1][1][1]int{}},
+	}
+	for _, test := range tests {
+		var w bytes.Buffer
+		if err := Marshal(&w, test.value); err != nil {
+			t.Fatal(err)
+		}
+		var buf Buffer
+		buf.SetLimits(Limits{MaxDepth: 2})
+		if err := buf.Unmarshal(&w, test.target); !errors.Is(err, ErrDepthExceeded) {
+			t.Errorf("Unmarshal of %T beyond max depth: got error %v, want %v", test.value, err, ErrDepthExceeded)
+		}
+	}
+}
+
+func TestUnmarshalMaxDepthDeep(t *testing.T) {
+	type tree struct {
+		C []tree
+	}
+	var value tree
+	for i := 0; i < 100000; i++ {
+		value = tree{[]tree{value}}
+	}
+	var w bytes.Buffer
+	if err := Marshal(&w, &value); err != nil {
+		t.Fatal(err)
+	}
+	var buf Buffer
+	buf.SetLimits(Limits{MaxDepth: 10})
+	if err := buf.Unmarshal(&w, &tree{}); !errors.Is(err, ErrDepthExceeded) {
+		t.Errorf("Unmarshal of deep input: got error %v, want %v", err, ErrDepthExceeded)
+	}
+}
+
+func TestUnmarshalEmptyElements(t *testing.T) {
+	type empty struct{}
+	type value struct {
+		Structs []empty
+		Arrays  [][0]int
+		Map     map[empty][0]string
+		Ints    []int32
+	}
+	want := value{
+		Structs: make([]empty, 100),
+		Arrays:  make([][0]int, 100),
+		Map:     map[empty][0]string{{}: {}},
+		Ints:    []int32{1},
+	}
+	var w bytes.Buffer
+	if err := Marshal(&w, &want); err != nil {
+		t.Fatal(err)
+	}
+	var buf Buffer
+	buf.SetLimits(Limits{MaxMessageBytes: int64(w.Len())})
+	var got value
+	if err := buf.Reader(&w).Unmarshal(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unmarshal = %+v, want %+v", got, want)
+	}
+
+	// A length of non-empty elements beyond the message is still rejected.
+	w.Reset()
+	if err := Marshal(&w, &value{Ints: []int32{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	buf.SetLimits(Limits{MaxMessageBytes: int64(w.Len() - 4)})
+	if err := buf.Reader(&w).Unmarshal(&got); !errors.Is(err, ErrLengthExceeded) {
+		t.Errorf("Unmarshal beyond message: got error %v, want %v", err, ErrLengthExceeded)
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	value := marshalAll{Strings: []string{"a", "b", "c"}, Map: map[string][]int64{"a": {1}}}
+	var buf Buffer
+	for i := 0; i < b.N; i++ {
+		if err := buf.Marshal(io.Discard, &value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package bufrw
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"io"
+	"reflect"
+)
+
+// WriteMarshaler writes val to w, where val implements SerializableToBufRW,
+// Serializable, encoding.BinaryMarshaler or encoding.TextMarshaler, tried
+// in that order. Values implementing SerializableToBufRW are written with
+// WriteSerializableBufRW; the others are written as the byte values of
+// their serialized form with WriteByteValues, like WriteSerializable.
+func (buf *Buffer) WriteMarshaler(w io.Writer, val any) error {
+	var b []byte
+	var err error
+	switch v := val.(type) {
+	case SerializableToBufRW:
+		return buf.WriteSerializableBufRW(w, v)
+	case Serializable:
+		b, err = v.Serialize()
+	case encoding.BinaryMarshaler:
+		b, err = v.MarshalBinary()
+	case encoding.TextMarshaler:
+		b, err = v.MarshalText()
+	default:
+		return &UnsupportedTypeError{reflect.TypeOf(val)}
+	}
+	if err != nil {
+		return err
+	}
+	return buf.WriteByteValues(w, b...)
+}
+
+// ReadMarshaler reads a value into val from r, where r reads from a source
+// that has used WriteMarshaler to write the value. val must implement
+// SerializableToBufRW, Serializable, encoding.BinaryUnmarshaler or
+// encoding.TextUnmarshaler, tried in that order, matching the interface
+// used by the writer.
+func (buf *Buffer) ReadMarshaler(r io.Reader, val any) error {
+	var unmarshal func(b []byte) error
+	switch v := val.(type) {
+	case SerializableToBufRW:
+		return buf.ReadSerializableBufRW(r, v)
+	case Serializable:
+		unmarshal = v.Deserialize
+	case encoding.BinaryUnmarshaler:
+		unmarshal = v.UnmarshalBinary
+	case encoding.TextUnmarshaler:
+		unmarshal = v.UnmarshalText
+	default:
+		return &UnsupportedTypeError{reflect.TypeOf(val)}
+	}
+	b, err := buf.ReadByteValues(r)
+	if err != nil {
+		return err
+	}
+	return unmarshal(b)
+}
+
+// BinaryAdapter adapts a Serializable value to encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler, so that it can be used by packages such as
+// encoding/gob. Values implementing SerializableToBufRW are serialized
+// with a new Buffer.
+type BinaryAdapter struct {
+	Value Serializable
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (a BinaryAdapter) MarshalBinary() ([]byte, error) {
+	s, ok := a.Value.(SerializableToBufRW)
+	if !ok {
+		return a.Value.Serialize()
+	}
+	var b bytes.Buffer
+	if err := s.SerializeToBufRW(&b, &Buffer{}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (a BinaryAdapter) UnmarshalBinary(b []byte) error {
+	s, ok := a.Value.(SerializableToBufRW)
+	if !ok {
+		return a.Value.Deserialize(b)
+	}
+	return s.DeserializeFromBufRW(bytes.NewReader(b), &Buffer{})
+}
+
+// TextAdapter adapts a Serializable value to encoding.TextMarshaler and
+// encoding.TextUnmarshaler, so that it can be used by packages such as
+// encoding/json. The text is the standard base64 encoding of the binary
+// form written by BinaryAdapter.
+type TextAdapter struct {
+	Value Serializable
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a TextAdapter) MarshalText() ([]byte, error) {
+	b, err := BinaryAdapter(a).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	text := make([]byte, base64.StdEncoding.EncodedLen(len(b)))
+	base64.StdEncoding.Encode(text, b)
+	return text, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a TextAdapter) UnmarshalText(text []byte) error {
+	b := make([]byte, base64.StdEncoding.DecodedLen(len(text)))
+	n, err := base64.StdEncoding.Decode(b, text)
+	if err != nil {
+		return err
+	}
+	return BinaryAdapter(a).UnmarshalBinary(b[:n])
+}
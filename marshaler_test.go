@@ -0,0 +1,156 @@
+package bufrw
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBufferReadWriteMarshaler(t *testing.T) {
+	bigInt, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	date := time.Date(2024, 2, 29, 12, 30, 0, 0, time.UTC)
+	ip := net.IPv4(192, 0, 2, 1)
+	link := &url.URL{Scheme: "https", Host: "example.com", Path: "/path"}
+	tests := []struct {
+		value any
+		read  any
+		want  any
+	}{
+		{date, new(time.Time), &date},
+		{bigInt, new(big.Int), bigInt},
+		{ip, new(net.IP), &ip},
+		{link, new(url.URL), link},
+		{&serializableString{"value"}, &serializableString{}, &serializableString{"VALUE"}},
+		{&adapted{"name", 1}, &adapted{}, &adapted{"name", 1}},
+	}
+	for _, test := range tests {
+		var buf Buffer
+		var b bytes.Buffer
+		w := buf.Writer(&b)
+		w.WriteMarshaler(test.value)
+		if err := w.Err(); err != nil {
+			t.Fatalf("WriteMarshaler(%v) error: %v", test.value, err)
+		}
+		if err := buf.Reader(&b).ReadMarshaler(test.read); err != nil {
+			t.Fatalf("ReadMarshaler() of %v error: %v", test.value, err)
+		}
+		if !reflect.DeepEqual(test.read, test.want) {
+			t.Errorf("Write/Read %v = %v", test.value, test.read)
+		}
+		if b.Len() != 0 {
+			t.Errorf("Write/Read %v left %d unread bytes", test.value, b.Len())
+		}
+	}
+}
+
+func TestBufferWriteMarshalerUnsupported(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	var unsupported *UnsupportedTypeError
+	if err := buf.WriteMarshaler(&b, 42); !errors.As(err, &unsupported) {
+		t.Errorf("WriteMarshaler(42) error is %v, want %T", err, unsupported)
+	}
+	var i int
+	if err := buf.ReadMarshaler(&b, &i); !errors.As(err, &unsupported) {
+		t.Errorf("ReadMarshaler(*int) error is %v, want %T", err, unsupported)
+	}
+}
+
+func TestMarshalMarshalers(t *testing.T) {
+	type record struct {
+		Amount *big.Int
+		Addr   net.IP
+		Link   url.URL
+	}
+	value := record{
+		Amount: big.NewInt(-42),
+		Addr:   net.ParseIP("2001:db8::1"),
+		Link:   url.URL{Scheme: "https", Host: "example.com"},
+	}
+	var buf Buffer
+	var b bytes.Buffer
+	if err := buf.Marshal(&b, value); err != nil {
+		t.Fatal(err)
+	}
+	var got record
+	if err := buf.Unmarshal(&b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Amount.Cmp(value.Amount) != 0 || !got.Addr.Equal(value.Addr) || got.Link != value.Link {
+		t.Errorf("Marshal/Unmarshal %+v = %+v", value, got)
+	}
+}
+
+type adapted struct {
+	Name  string
+	Value int64
+}
+
+func (a *adapted) Serialize() ([]byte, error) { return nil, errors.New("not implemented") }
+func (a *adapted) Deserialize([]byte) error   { return errors.New("not implemented") }
+
+func (a *adapted) SerializeToBufRW(w io.Writer, buf *Buffer) error {
+	if err := buf.WriteString(w, a.Name); err != nil {
+		return err
+	}
+	return buf.WriteInt64(w, a.Value)
+}
+
+func (a *adapted) DeserializeFromBufRW(r io.Reader, buf *Buffer) error {
+	var err error
+	if a.Name, err = buf.ReadString(r); err != nil {
+		return err
+	}
+	a.Value, err = buf.ReadInt64(r)
+	return err
+}
+
+func TestBinaryAdapter(t *testing.T) {
+	value := &adapted{Name: "name", Value: -7}
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(BinaryAdapter{value}); err != nil {
+		t.Fatal(err)
+	}
+	got := &adapted{}
+	if err := gob.NewDecoder(&b).Decode(&BinaryAdapter{got}); err != nil {
+		t.Fatal(err)
+	}
+	if *got != *value {
+		t.Errorf("gob round trip of %+v = %+v", value, got)
+	}
+
+	s := &serializableString{"value"}
+	data, err := BinaryAdapter{s}.MarshalBinary()
+	if err != nil || string(data) != "VALUE" {
+		t.Errorf("MarshalBinary() of Serializable = %q, %v, want %q", data, err, "VALUE")
+	}
+}
+
+func TestTextAdapter(t *testing.T) {
+	value := &adapted{Name: "name", Value: 1 << 40}
+	data, err := json.Marshal(map[string]any{"value": TextAdapter{value}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &adapted{}
+	wrapper := struct {
+		Value TextAdapter `json:"value"`
+	}{TextAdapter{got}}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		t.Fatal(err)
+	}
+	if *got != *value {
+		t.Errorf("JSON round trip of %+v through %s = %+v", value, data, got)
+	}
+	if err := (TextAdapter{got}).UnmarshalText([]byte("not base64!")); err == nil {
+		t.Error("UnmarshalText() of invalid text succeeded")
+	}
+}
@@ -0,0 +1,89 @@
+package bufrw
+
+import (
+	"io"
+	"reflect"
+)
+
+// nilLen is the length prefix marking a nil value.
+const nilLen = -1
+
+// SetNullable sets whether the buffer writes nil slices and maps with a
+// length prefix of -1 rather than 0, so that they are read back as nil
+// rather than as empty values. Readers always read a length of -1 as nil,
+// whatever the setting.
+//
+// Note that calling a plural method such as WriteInts without values
+// passes a nil slice, which is written as nil if the buffer is nullable.
+func (buf *Buffer) SetNullable(nullable bool) {
+	buf.nullable = nullable
+}
+
+// WriteNullableLen writes the length prefix of a collection of n values
+// to w like WriteLen. If isNil is true and the buffer is nullable, the
+// length prefix marks the collection as nil.
+func (buf *Buffer) WriteNullableLen(w io.Writer, n int, isNil bool) error {
+	if isNil && buf.nullable {
+		n = nilLen
+	}
+	return buf.WriteLen(w, n)
+}
+
+// ReadNullableLen reads the length prefix of a collection from r like
+// ReadLen, but returns -1 if the collection is nil.
+func (buf *Buffer) ReadNullableLen(r io.Reader) (int, error) {
+	return buf.readNullableLen(r, buf.limits.MaxSliceLen, 1)
+}
+
+// WriteNullableString writes a string value to w, which may be nil. A nil
+// value is written as a length prefix of -1, whether or not the buffer is
+// nullable.
+func (buf *Buffer) WriteNullableString(w io.Writer, val *string) error {
+	if val == nil {
+		return buf.WriteLen(w, nilLen)
+	}
+	return buf.WriteString(w, *val)
+}
+
+// ReadNullableString reads a string value from r, where r reads from a
+// source that has used WriteNullableString or WriteString to write a
+// string value. It returns nil if a nil value was written.
+func (buf *Buffer) ReadNullableString(r io.Reader) (*string, error) {
+	n, err := buf.readNullableLen(r, buf.limits.MaxStringLen, 1)
+	if err != nil || n == nilLen {
+		return nil, err
+	}
+	b, err := buf.Read(r, n)
+	if err != nil {
+		return nil, err
+	}
+	val := string(b)
+	return &val, nil
+}
+
+// WriteNullableSerializable writes a serializable value to w, which may be
+// nil or a nil pointer. A boolean telling whether the value is present is
+// written before the value itself, which is written with
+// WriteSerializable.
+func (buf *Buffer) WriteNullableSerializable(w io.Writer, val Serializable) error {
+	present := val != nil
+	if v := reflect.ValueOf(val); present && v.Kind() == reflect.Pointer {
+		present = !v.IsNil()
+	}
+	if err := buf.WriteBool(w, present); err != nil || !present {
+		return err
+	}
+	return buf.WriteSerializable(w, val)
+}
+
+// ReadNullableSerializable reads a serializable value into val from r,
+// where r reads from a source that has used WriteNullableSerializable to
+// write the value. It reports whether a value was present; if not, val is
+// left unchanged.
+func (buf *Buffer) ReadNullableSerializable(r io.Reader, val Serializable) (bool, error) {
+	present, err := buf.ReadBool(r)
+	if err != nil || !present {
+		return false, err
+	}
+	return true, buf.ReadSerializable(r, val)
+}
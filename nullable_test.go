@@ -0,0 +1,141 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestBufferNullable(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(buf *Buffer, w io.Writer) error
+		read  func(buf *Buffer, r io.Reader) (any, error)
+		empty any
+	}{
+		{
+			name:  "ints",
+			write: func(buf *Buffer, w io.Writer) error { return buf.WriteInts(w) },
+			read:  func(buf *Buffer, r io.Reader) (any, error) { return buf.ReadInts(r) },
+			empty: []int{},
+		},
+		{
+			name:  "strings",
+			write: func(buf *Buffer, w io.Writer) error { return buf.WriteStrings(w) },
+			read:  func(buf *Buffer, r io.Reader) (any, error) { return buf.ReadStrings(r) },
+			empty: []string{},
+		},
+		{
+			name:  "byte values",
+			write: func(buf *Buffer, w io.Writer) error { return buf.WriteByteValues(w) },
+			read:  func(buf *Buffer, r io.Reader) (any, error) { return buf.ReadByteValues(r) },
+			empty: []byte{},
+		},
+		{
+			name:  "packed bools",
+			write: func(buf *Buffer, w io.Writer) error { return buf.WritePackedBools(w) },
+			read:  func(buf *Buffer, r io.Reader) (any, error) { return buf.ReadPackedBools(r) },
+			empty: []bool{},
+		},
+		{
+			name: "map",
+			write: func(buf *Buffer, w io.Writer) error {
+				return WriteSortedMap(buf, w, map[string]int64(nil), (*Buffer).WriteString, (*Buffer).WriteInt64)
+			},
+			read: func(buf *Buffer, r io.Reader) (any, error) {
+				return ReadMap(buf, r, (*Buffer).ReadString, (*Buffer).ReadInt64)
+			},
+			empty: map[string]int64{},
+		},
+	}
+	for _, test := range tests {
+		for _, nullable := range []bool{false, true} {
+			var buf Buffer
+			buf.SetNullable(nullable)
+			var b bytes.Buffer
+			if err := test.write(&buf, &b); err != nil {
+				t.Fatal(err)
+			}
+			got, err := test.read(&buf, &b)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if want := test.empty; nullable {
+				if !reflect.ValueOf(got).IsNil() {
+					t.Errorf("%s: read %#v from nullable buffer, want nil", test.name, got)
+				}
+			} else if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: read %#v, want %#v", test.name, got, want)
+			}
+		}
+	}
+}
+
+func TestBufferNullableEmpty(t *testing.T) {
+	var buf Buffer
+	buf.SetNullable(true)
+	var b bytes.Buffer
+	if err := buf.WriteInt64s(&b, []int64{}...); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSlice(&buf, &b, []string{}, (*Buffer).WriteString); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := buf.ReadInt64s(&b); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("ReadInt64s() of empty slice = %#v, %v, want empty slice", got, err)
+	}
+	if got, err := buf.ReadStrings(&b); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("ReadStrings() of empty slice = %#v, %v, want empty slice", got, err)
+	}
+}
+
+func TestBufferReadNilLength(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	if err := buf.WriteNullableString(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buf.ReadString(&b); !errors.Is(err, ErrNegativeLength) {
+		t.Errorf("ReadString() of nil string: got error %v, want %v", err, ErrNegativeLength)
+	}
+}
+
+func TestBufferReadWriteNullableString(t *testing.T) {
+	empty, value := "", "value"
+	testReadWrite(t, []*string{nil, &empty, &value}, (*Buffer).WriteNullableString, (*Buffer).ReadNullableString)
+
+	var buf Buffer
+	var b bytes.Buffer
+	if err := buf.WriteString(&b, value); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := buf.ReadNullableString(&b); err != nil || got == nil || *got != value {
+		t.Errorf("ReadNullableString() of WriteString value = %v, %v, want %q", got, err, value)
+	}
+}
+
+func TestBufferReadWriteNullableSerializable(t *testing.T) {
+	var nilPointer *serializableString
+	for _, value := range []Serializable{nil, nilPointer, &serializableString{"value"}} {
+		var buf Buffer
+		var b bytes.Buffer
+		w := buf.Writer(&b)
+		w.WriteNullableSerializable(value)
+		if err := w.Err(); err != nil {
+			t.Fatal(err)
+		}
+		var got serializableString
+		ok, err := buf.Reader(&b).ReadNullableSerializable(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := value != nil && value != Serializable(nilPointer); ok != want {
+			t.Errorf("ReadNullableSerializable() of %#v reported %v, want %v", value, ok, want)
+		}
+		if ok && got.value != "VALUE" {
+			t.Errorf("ReadNullableSerializable() read %q, want %q", got.value, "VALUE")
+		}
+	}
+}
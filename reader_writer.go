@@ -1,102 +1,698 @@
 package bufrw
 
-import "io"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
 
 type Writer struct {
 	buf         *Buffer
 	w           io.Writer
 	stopOnError bool
 	err         error
+
+	// parent and msg are set on writers returned by BeginMessage, which
+	// buffer the sub-message in msg until it is written to parent.
+	parent *Writer
+	msg    *bytes.Buffer
+
+	// tagged is set on writers returned by TaggedWriter. fieldNum is the
+	// field number set by Field for the next value, and msgField the one
+	// of a sub-message, written by End.
+	tagged   bool
+	fieldNum int
+	msgField int
 }
 
-func (w *Writer) do(fn func() error) error {
+// do calls fn to write a single value of the given kind, recording any
+// error. Tagged writers first write the tag of the value, unless kind is
+// KindInvalid. If w stops on errors and a previous write has failed, fn
+// is not called and the previous error is returned.
+func (w *Writer) do(kind Kind, fn func() error) error {
 	if w.err != nil && w.stopOnError {
 		return w.err
 	}
-	w.err = fn()
+	w.err = w.writeTag(kind)
+	if w.err == nil {
+		w.err = fn()
+	}
 	return w.err
 }
 
 func (w *Writer) WriteBool(val bool) error {
-	return w.do(func() error { return w.buf.WriteBool(w.w, val) })
+	return w.do(KindBool, func() error { return w.buf.WriteBool(w.w, val) })
 }
 
 func (w *Writer) WriteBools(val ...bool) error {
-	return w.do(func() error { return w.buf.WriteBools(w.w, val...) })
+	return w.do(KindBools, func() error { return w.buf.WriteBools(w.w, val...) })
+}
+
+func (w *Writer) WritePackedBools(val ...bool) error {
+	return w.do(KindPackedBools, func() error { return w.buf.WritePackedBools(w.w, val...) })
+}
+
+func (w *Writer) WriteBitset(words ...uint64) error {
+	return w.do(KindBitset, func() error { return w.buf.WriteBitset(w.w, words...) })
 }
 
 func (w *Writer) WriteByteValue(val byte) error {
-	return w.do(func() error { return w.buf.WriteByteValue(w.w, val) })
+	return w.do(KindUint8, func() error { return w.buf.WriteByteValue(w.w, val) })
 }
 
 func (w *Writer) WriteByteValues(val ...byte) error {
-	return w.do(func() error { return w.buf.WriteByteValues(w.w, val...) })
+	return w.do(KindByteValues, func() error { return w.buf.WriteByteValues(w.w, val...) })
+}
+
+func (w *Writer) WriteLen(n int) error {
+	return w.do(w.buf.lenKind(), func() error { return w.buf.WriteLen(w.w, n) })
+}
+
+func (w *Writer) WriteNullableLen(n int, isNil bool) error {
+	return w.do(w.buf.lenKind(), func() error { return w.buf.WriteNullableLen(w.w, n, isNil) })
 }
 
 func (w *Writer) WriteInt(val int) error {
-	return w.do(func() error { return w.buf.WriteInt(w.w, val) })
+	return w.do(KindInt, func() error { return w.buf.WriteInt(w.w, val) })
 }
 
 func (w *Writer) WriteInts(val ...int) error {
-	return w.do(func() error { return w.buf.WriteInts(w.w, val...) })
+	return w.do(KindInts, func() error { return w.buf.WriteInts(w.w, val...) })
 }
 
 func (w *Writer) WriteInt64(val int64) error {
-	return w.do(func() error { return w.buf.WriteInt64(w.w, val) })
+	return w.do(KindInt64, func() error { return w.buf.WriteInt64(w.w, val) })
 }
 
 func (w *Writer) WriteInt64s(val ...int64) error {
-	return w.do(func() error { return w.buf.WriteInt64s(w.w, val...) })
+	return w.do(KindInt64s, func() error { return w.buf.WriteInt64s(w.w, val...) })
+}
+
+func (w *Writer) WriteInt8(val int8) error {
+	return w.do(KindInt8, func() error { return w.buf.WriteInt8(w.w, val) })
+}
+
+func (w *Writer) WriteInt8s(val ...int8) error {
+	return w.do(KindInt8s, func() error { return w.buf.WriteInt8s(w.w, val...) })
+}
+
+func (w *Writer) WriteInt16(val int16) error {
+	return w.do(KindInt16, func() error { return w.buf.WriteInt16(w.w, val) })
+}
+
+func (w *Writer) WriteInt16s(val ...int16) error {
+	return w.do(KindInt16s, func() error { return w.buf.WriteInt16s(w.w, val...) })
+}
+
+func (w *Writer) WriteInt32(val int32) error {
+	return w.do(KindInt32, func() error { return w.buf.WriteInt32(w.w, val) })
+}
+
+func (w *Writer) WriteInt32s(val ...int32) error {
+	return w.do(KindInt32s, func() error { return w.buf.WriteInt32s(w.w, val...) })
+}
+
+func (w *Writer) WriteUint8(val uint8) error {
+	return w.do(KindUint8, func() error { return w.buf.WriteUint8(w.w, val) })
+}
+
+func (w *Writer) WriteUint8s(val ...uint8) error {
+	return w.do(KindByteValues, func() error { return w.buf.WriteUint8s(w.w, val...) })
+}
+
+func (w *Writer) WriteUint16(val uint16) error {
+	return w.do(KindUint16, func() error { return w.buf.WriteUint16(w.w, val) })
+}
+
+func (w *Writer) WriteUint16s(val ...uint16) error {
+	return w.do(KindUint16s, func() error { return w.buf.WriteUint16s(w.w, val...) })
+}
+
+func (w *Writer) WriteUint32(val uint32) error {
+	return w.do(KindUint32, func() error { return w.buf.WriteUint32(w.w, val) })
+}
+
+func (w *Writer) WriteUint32s(val ...uint32) error {
+	return w.do(KindUint32s, func() error { return w.buf.WriteUint32s(w.w, val...) })
+}
+
+func (w *Writer) WriteUint64(val uint64) error {
+	return w.do(KindUint64, func() error { return w.buf.WriteUint64(w.w, val) })
+}
+
+func (w *Writer) WriteUint64s(val ...uint64) error {
+	return w.do(KindUint64s, func() error { return w.buf.WriteUint64s(w.w, val...) })
+}
+
+func (w *Writer) WriteUvarint(val uint64) error {
+	return w.do(KindUvarint, func() error { return w.buf.WriteUvarint(w.w, val) })
+}
+
+func (w *Writer) WriteVarint(val int64) error {
+	return w.do(KindVarint, func() error { return w.buf.WriteVarint(w.w, val) })
+}
+
+func (w *Writer) WriteFloat32(val float32) error {
+	return w.do(KindFloat32, func() error { return w.buf.WriteFloat32(w.w, val) })
+}
+
+func (w *Writer) WriteFloat32s(val ...float32) error {
+	return w.do(KindFloat32s, func() error { return w.buf.WriteFloat32s(w.w, val...) })
 }
 
 func (w *Writer) WriteFloat64(val float64) error {
-	return w.do(func() error { return w.buf.WriteFloat64(w.w, val) })
+	return w.do(KindFloat64, func() error { return w.buf.WriteFloat64(w.w, val) })
 }
 
 func (w *Writer) WriteFloat64s(val ...float64) error {
-	return w.do(func() error { return w.buf.WriteFloat64s(w.w, val...) })
+	return w.do(KindFloat64s, func() error { return w.buf.WriteFloat64s(w.w, val...) })
+}
+
+func (w *Writer) WriteComplex64(val complex64) error {
+	return w.do(KindComplex64, func() error { return w.buf.WriteComplex64(w.w, val) })
+}
+
+func (w *Writer) WriteComplex64s(val ...complex64) error {
+	return w.do(KindComplex64s, func() error { return w.buf.WriteComplex64s(w.w, val...) })
+}
+
+func (w *Writer) WriteComplex128(val complex128) error {
+	return w.do(KindComplex128, func() error { return w.buf.WriteComplex128(w.w, val) })
+}
+
+func (w *Writer) WriteComplex128s(val ...complex128) error {
+	return w.do(KindComplex128s, func() error { return w.buf.WriteComplex128s(w.w, val...) })
+}
+
+func (w *Writer) WriteTime(val time.Time) error {
+	return w.do(KindTime, func() error { return w.buf.WriteTime(w.w, val) })
+}
+
+func (w *Writer) WriteDuration(val time.Duration) error {
+	return w.do(KindDuration, func() error { return w.buf.WriteDuration(w.w, val) })
 }
 
 func (w *Writer) WriteString(val string) error {
-	return w.do(func() error { return w.buf.WriteString(w.w, val) })
+	return w.do(KindString, func() error { return w.buf.WriteString(w.w, val) })
 }
 
 func (w *Writer) WriteStrings(val ...string) error {
-	return w.do(func() error { return w.buf.WriteStrings(w.w, val...) })
+	return w.do(KindStrings, func() error { return w.buf.WriteStrings(w.w, val...) })
 }
 
 func (w *Writer) WriteSerializable(val Serializable) error {
-	return w.do(func() error { return w.buf.WriteSerializable(w.w, val) })
+	return w.opaque(func(dst io.Writer) error { return w.buf.WriteSerializable(dst, val) })
+}
+
+func (w *Writer) WriteMarshaler(val any) error {
+	return w.opaque(func(dst io.Writer) error { return w.buf.WriteMarshaler(dst, val) })
+}
+
+func (w *Writer) WriteNullableString(val *string) error {
+	return w.do(KindString, func() error { return w.buf.WriteNullableString(w.w, val) })
+}
+
+func (w *Writer) WriteNullableSerializable(val Serializable) error {
+	return w.opaque(func(dst io.Writer) error { return w.buf.WriteNullableSerializable(dst, val) })
+}
+
+func (w *Writer) Marshal(v any) error {
+	return w.opaque(func(dst io.Writer) error { return w.buf.Marshal(dst, v) })
+}
+
+func (w *Writer) WriteRegistered(val Serializable) error {
+	return w.opaque(func(dst io.Writer) error { return w.buf.WriteRegistered(dst, val) })
 }
 
 func (w *Writer) Err() error {
 	return w.err
 }
 
+// BeginMessage begins a length-delimited sub-message of w, returning a
+// Writer for its values. The values are buffered until End is called,
+// which writes their length in bytes with WriteLen followed by the values
+// to w. Sub-messages may be nested, and are read with Reader.SubReader.
+//
+// The returned Writer stops on errors, since a partially written
+// sub-message cannot be read.
+func (w *Writer) BeginMessage() *Writer {
+	msg := new(bytes.Buffer)
+	child := &Writer{buf: w.buf, w: msg, stopOnError: true, parent: w, msg: msg, tagged: w.tagged, msgField: w.fieldNum}
+	w.fieldNum = 0
+	return child
+}
+
+// End ends a sub-message begun with BeginMessage, writing it to the
+// parent Writer. If writing a value of the sub-message failed, nothing is
+// written and the error is returned and recorded by the parent Writer.
+func (w *Writer) End() error {
+	parent := w.parent
+	if parent == nil {
+		return errors.New("bufrw: End of a Writer not returned by BeginMessage")
+	}
+	w.parent = nil
+	if w.err != nil {
+		return parent.do(KindInvalid, func() error { return w.err })
+	}
+	parent.fieldNum = w.msgField
+	return parent.do(KindMessage, func() error {
+		if err := parent.buf.WriteLen(parent.w, w.msg.Len()); err != nil {
+			return err
+		}
+		_, err := parent.w.Write(w.msg.Bytes())
+		return err
+	})
+}
+
 func (buf *Buffer) Writer(w io.Writer, stopOnError ...bool) *Writer {
 	return &Writer{buf: buf, w: w, stopOnError: len(stopOnError) > 0 && stopOnError[0]}
 }
 
 type Reader struct {
-	buf *Buffer
-	r   io.Reader
-}
-
-func (r *Reader) Read(n int) ([]byte, error)              { return r.buf.Read(r.r, n) }
-func (r *Reader) ReadBool() (bool, error)                 { return r.buf.ReadBool(r.r) }
-func (r *Reader) ReadBools() ([]bool, error)              { return r.buf.ReadBools(r.r) }
-func (r *Reader) ReadByteValue() (byte, error)            { return r.buf.ReadByteValue(r.r) }
-func (r *Reader) ReadByteValues() ([]byte, error)         { return r.buf.ReadByteValues(r.r) }
-func (r *Reader) ReadInt() (int, error)                   { return r.buf.ReadInt(r.r) }
-func (r *Reader) ReadInts() ([]int, error)                { return r.buf.ReadInts(r.r) }
-func (r *Reader) ReadInt64() (int64, error)               { return r.buf.ReadInt64(r.r) }
-func (r *Reader) ReadInt64s() ([]int64, error)            { return r.buf.ReadInt64s(r.r) }
-func (r *Reader) ReadFloat64() (float64, error)           { return r.buf.ReadFloat64(r.r) }
-func (r *Reader) ReadFloat64s() ([]float64, error)        { return r.buf.ReadFloat64s(r.r) }
-func (r *Reader) ReadString() (string, error)             { return r.buf.ReadString(r.r) }
-func (r *Reader) ReadStrings() ([]string, error)          { return r.buf.ReadStrings(r.r) }
-func (r *Reader) ReadSerializable(val Serializable) error { return r.buf.ReadSerializable(r.r, val) }
-
-func (buf *Buffer) Reader(r io.Reader) *Reader {
-	return &Reader{buf: buf, r: r}
+	buf         *Buffer
+	r           *countingReader
+	stopOnError bool
+	field       int
+	err         *ReadError
+
+	// frame is set on readers returned by SubReader, limiting reads to the
+	// remainder of the sub-message.
+	frame *io.LimitedReader
+
+	// tagged is set on readers returned by TaggedReader. If hasTag is set,
+	// the tag of the next value has been read by ReadTag.
+	tagged   bool
+	hasTag   bool
+	tagKind  Kind
+	tagField int
+}
+
+// ReadError describes a failed read from a Reader, recording the index of
+// the field being read and the byte offset at which the field started.
+type ReadError struct {
+	Field  int
+	Offset int64
+	Err    error
+}
+
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("bufrw: reading field %d at offset %d: %v", e.Field, e.Offset, e.Err)
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// read calls fn to read a single field of the given kind, recording any
+// error. Tagged readers first read and check the tag of the field, unless
+// kind is KindInvalid. If r stops on errors and a previous read has
+// failed, fn is not called and the zero value is returned along with the
+// previous error.
+func read[T any](r *Reader, kind Kind, fn func() (T, error)) (T, error) {
+	if r.err != nil && r.stopOnError {
+		var zero T
+		return zero, r.err.Err
+	}
+	offset := r.r.n
+	var val T
+	err := r.expectTag(kind)
+	if err == nil {
+		val, err = fn()
+	}
+	if err != nil {
+		r.err = &ReadError{Field: r.field, Offset: offset, Err: err}
+	} else {
+		r.err = nil
+	}
+	r.field++
+	return val, err
+}
+
+func (r *Reader) Read(n int) ([]byte, error) {
+	return read(r, KindInvalid, func() ([]byte, error) { return r.buf.Read(r.r, n) })
+}
+
+func (r *Reader) ReadBool() (bool, error) {
+	return read(r, KindBool, func() (bool, error) { return r.buf.ReadBool(r.r) })
+}
+
+func (r *Reader) ReadBools() ([]bool, error) {
+	return read(r, KindBools, func() ([]bool, error) { return r.buf.ReadBools(r.r) })
+}
+
+func (r *Reader) ReadPackedBools() ([]bool, error) {
+	return read(r, KindPackedBools, func() ([]bool, error) { return r.buf.ReadPackedBools(r.r) })
+}
+
+func (r *Reader) ReadPackedBoolsMax(max int) ([]bool, error) {
+	return read(r, KindPackedBools, func() ([]bool, error) { return r.buf.ReadPackedBoolsMax(r.r, max) })
+}
+
+func (r *Reader) ReadBitset() ([]uint64, error) {
+	return read(r, KindBitset, func() ([]uint64, error) { return r.buf.ReadBitset(r.r) })
+}
+
+func (r *Reader) ReadByteValue() (byte, error) {
+	return read(r, KindUint8, func() (byte, error) { return r.buf.ReadByteValue(r.r) })
+}
+
+func (r *Reader) ReadByteValues() ([]byte, error) {
+	return read(r, KindByteValues, func() ([]byte, error) { return r.buf.ReadByteValues(r.r) })
+}
+
+func (r *Reader) ReadByteValuesInto(dst []byte) ([]byte, error) {
+	return read(r, KindByteValues, func() ([]byte, error) { return r.buf.ReadByteValuesInto(r.r, dst) })
+}
+
+func (r *Reader) ReadByteValuesBorrowed() ([]byte, error) {
+	return read(r, KindByteValues, func() ([]byte, error) { return r.buf.ReadByteValuesBorrowed(r.r) })
+}
+
+func (r *Reader) ReadLen() (int, error) {
+	return read(r, r.buf.lenKind(), func() (int, error) { return r.buf.ReadLen(r.r) })
+}
+
+func (r *Reader) ReadNullableLen() (int, error) {
+	return read(r, r.buf.lenKind(), func() (int, error) { return r.buf.ReadNullableLen(r.r) })
+}
+
+func (r *Reader) ReadInt() (int, error) {
+	return read(r, KindInt, func() (int, error) { return r.buf.ReadInt(r.r) })
+}
+
+func (r *Reader) ReadInts() ([]int, error) {
+	return read(r, KindInts, func() ([]int, error) { return r.buf.ReadInts(r.r) })
+}
+
+func (r *Reader) ReadInt64() (int64, error) {
+	return read(r, KindInt64, func() (int64, error) { return r.buf.ReadInt64(r.r) })
+}
+
+func (r *Reader) ReadInt64s() ([]int64, error) {
+	return read(r, KindInt64s, func() ([]int64, error) { return r.buf.ReadInt64s(r.r) })
+}
+
+func (r *Reader) ReadInt8() (int8, error) {
+	return read(r, KindInt8, func() (int8, error) { return r.buf.ReadInt8(r.r) })
+}
+
+func (r *Reader) ReadInt8s() ([]int8, error) {
+	return read(r, KindInt8s, func() ([]int8, error) { return r.buf.ReadInt8s(r.r) })
+}
+
+func (r *Reader) ReadInt16() (int16, error) {
+	return read(r, KindInt16, func() (int16, error) { return r.buf.ReadInt16(r.r) })
+}
+
+func (r *Reader) ReadInt16s() ([]int16, error) {
+	return read(r, KindInt16s, func() ([]int16, error) { return r.buf.ReadInt16s(r.r) })
+}
+
+func (r *Reader) ReadInt32() (int32, error) {
+	return read(r, KindInt32, func() (int32, error) { return r.buf.ReadInt32(r.r) })
+}
+
+func (r *Reader) ReadInt32s() ([]int32, error) {
+	return read(r, KindInt32s, func() ([]int32, error) { return r.buf.ReadInt32s(r.r) })
+}
+
+func (r *Reader) ReadUint8() (uint8, error) {
+	return read(r, KindUint8, func() (uint8, error) { return r.buf.ReadUint8(r.r) })
+}
+
+func (r *Reader) ReadUint8s() ([]uint8, error) {
+	return read(r, KindByteValues, func() ([]uint8, error) { return r.buf.ReadUint8s(r.r) })
+}
+
+func (r *Reader) ReadUint16() (uint16, error) {
+	return read(r, KindUint16, func() (uint16, error) { return r.buf.ReadUint16(r.r) })
+}
+
+func (r *Reader) ReadUint16s() ([]uint16, error) {
+	return read(r, KindUint16s, func() ([]uint16, error) { return r.buf.ReadUint16s(r.r) })
+}
+
+func (r *Reader) ReadUint32() (uint32, error) {
+	return read(r, KindUint32, func() (uint32, error) { return r.buf.ReadUint32(r.r) })
+}
+
+func (r *Reader) ReadUint32s() ([]uint32, error) {
+	return read(r, KindUint32s, func() ([]uint32, error) { return r.buf.ReadUint32s(r.r) })
+}
+
+func (r *Reader) ReadUint64() (uint64, error) {
+	return read(r, KindUint64, func() (uint64, error) { return r.buf.ReadUint64(r.r) })
+}
+
+func (r *Reader) ReadUint64s() ([]uint64, error) {
+	return read(r, KindUint64s, func() ([]uint64, error) { return r.buf.ReadUint64s(r.r) })
+}
+
+func (r *Reader) ReadUvarint() (uint64, error) {
+	return read(r, KindUvarint, func() (uint64, error) { return r.buf.ReadUvarint(r.r) })
+}
+
+func (r *Reader) ReadVarint() (int64, error) {
+	return read(r, KindVarint, func() (int64, error) { return r.buf.ReadVarint(r.r) })
+}
+
+func (r *Reader) ReadFloat32() (float32, error) {
+	return read(r, KindFloat32, func() (float32, error) { return r.buf.ReadFloat32(r.r) })
+}
+
+func (r *Reader) ReadFloat32s() ([]float32, error) {
+	return read(r, KindFloat32s, func() ([]float32, error) { return r.buf.ReadFloat32s(r.r) })
+}
+
+func (r *Reader) ReadFloat64() (float64, error) {
+	return read(r, KindFloat64, func() (float64, error) { return r.buf.ReadFloat64(r.r) })
+}
+
+func (r *Reader) ReadFloat64s() ([]float64, error) {
+	return read(r, KindFloat64s, func() ([]float64, error) { return r.buf.ReadFloat64s(r.r) })
+}
+
+func (r *Reader) ReadComplex64() (complex64, error) {
+	return read(r, KindComplex64, func() (complex64, error) { return r.buf.ReadComplex64(r.r) })
+}
+
+func (r *Reader) ReadComplex64s() ([]complex64, error) {
+	return read(r, KindComplex64s, func() ([]complex64, error) { return r.buf.ReadComplex64s(r.r) })
+}
+
+func (r *Reader) ReadComplex128() (complex128, error) {
+	return read(r, KindComplex128, func() (complex128, error) { return r.buf.ReadComplex128(r.r) })
+}
+
+func (r *Reader) ReadComplex128s() ([]complex128, error) {
+	return read(r, KindComplex128s, func() ([]complex128, error) { return r.buf.ReadComplex128s(r.r) })
+}
+
+func (r *Reader) ReadTime() (time.Time, error) {
+	return read(r, KindTime, func() (time.Time, error) { return r.buf.ReadTime(r.r) })
+}
+
+func (r *Reader) ReadDuration() (time.Duration, error) {
+	return read(r, KindDuration, func() (time.Duration, error) { return r.buf.ReadDuration(r.r) })
+}
+
+func (r *Reader) ReadString() (string, error) {
+	return read(r, KindString, func() (string, error) { return r.buf.ReadString(r.r) })
+}
+
+func (r *Reader) ReadStringMax(max int) (string, error) {
+	return read(r, KindString, func() (string, error) { return r.buf.ReadStringMax(r.r, max) })
+}
+
+func (r *Reader) ReadStrings() ([]string, error) {
+	return read(r, KindStrings, func() ([]string, error) { return r.buf.ReadStrings(r.r) })
+}
+
+func (r *Reader) ReadSerializable(val Serializable) error {
+	_, err := readOpaque(r, func(src io.Reader) (struct{}, error) { return struct{}{}, r.buf.ReadSerializable(src, val) })
+	return err
+}
+
+func (r *Reader) ReadMarshaler(val any) error {
+	_, err := readOpaque(r, func(src io.Reader) (struct{}, error) { return struct{}{}, r.buf.ReadMarshaler(src, val) })
+	return err
+}
+
+func (r *Reader) ReadNullableString() (*string, error) {
+	return read(r, KindString, func() (*string, error) { return r.buf.ReadNullableString(r.r) })
+}
+
+func (r *Reader) ReadNullableSerializable(val Serializable) (bool, error) {
+	return readOpaque(r, func(src io.Reader) (bool, error) { return r.buf.ReadNullableSerializable(src, val) })
+}
+
+func (r *Reader) Unmarshal(v any) error {
+	_, err := readOpaque(r, func(src io.Reader) (struct{}, error) { return struct{}{}, r.buf.Unmarshal(src, v) })
+	return err
+}
+
+func (r *Reader) ReadRegistered() (Serializable, error) {
+	return readOpaque(r, r.buf.ReadRegistered)
+}
+
+// Err returns the error of the last read, or the first failed read if the
+// reader stops on errors. The returned error is a *ReadError.
+func (r *Reader) Err() error {
+	if r.err == nil {
+		return nil
+	}
+	return r.err
+}
+
+// SubReader reads the length of a sub-message written with
+// Writer.BeginMessage and returns a Reader limited to the sub-message.
+// Reads past the end of the sub-message fail, and the offsets of read
+// errors are relative to its start. Call Skip on the returned Reader to
+// discard any unread remainder before reading on from r.
+func (r *Reader) SubReader() (*Reader, error) {
+	return read(r, KindMessage, r.subReader)
+}
+
+// subReader reads the length of a sub-message and returns a Reader
+// limited to it.
+func (r *Reader) subReader() (*Reader, error) {
+	n, err := r.buf.readLen(r.r, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	frame := &io.LimitedReader{R: r.r, N: int64(n)}
+	return &Reader{
+		buf:         r.buf,
+		r:           &countingReader{r: frame, max: int64(n)},
+		stopOnError: r.stopOnError,
+		frame:       frame,
+		tagged:      r.tagged,
+	}, nil
+}
+
+// Skip discards the unread remainder of the sub-message of a Reader
+// returned by SubReader, so that its parent Reader continues after the
+// sub-message.
+func (r *Reader) Skip() error {
+	if r.frame == nil {
+		return errors.New("bufrw: Skip of a Reader not returned by SubReader")
+	}
+	if r.frame.N == 0 {
+		return nil
+	}
+	if err := discard(r.frame, r.frame.N); err != io.EOF {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// SkipKind reads past a value of the given kind. It is the Reader
+// counterpart of Buffer.Skip, named apart from Skip, which discards the
+// remainder of a sub-message.
+func (r *Reader) SkipKind(kind Kind) error {
+	_, err := read(r, kind, func() (struct{}, error) { return struct{}{}, r.buf.Skip(r.r, kind) })
+	return err
+}
+
+func (r *Reader) SkipString() error {
+	_, err := read(r, KindString, func() (struct{}, error) { return struct{}{}, r.buf.SkipString(r.r) })
+	return err
+}
+
+func (r *Reader) SkipStrings() error {
+	_, err := read(r, KindStrings, func() (struct{}, error) { return struct{}{}, r.buf.SkipStrings(r.r) })
+	return err
+}
+
+func (r *Reader) SkipByteValues() error {
+	_, err := read(r, KindByteValues, func() (struct{}, error) { return struct{}{}, r.buf.SkipByteValues(r.r) })
+	return err
+}
+
+func (r *Reader) SkipBools() error {
+	_, err := read(r, KindBools, func() (struct{}, error) { return struct{}{}, r.buf.SkipBools(r.r) })
+	return err
+}
+
+func (r *Reader) SkipInts() error {
+	_, err := read(r, KindInts, func() (struct{}, error) { return struct{}{}, r.buf.SkipInts(r.r) })
+	return err
+}
+
+func (r *Reader) SkipInt8s() error {
+	_, err := read(r, KindInt8s, func() (struct{}, error) { return struct{}{}, r.buf.SkipInt8s(r.r) })
+	return err
+}
+
+func (r *Reader) SkipInt16s() error {
+	_, err := read(r, KindInt16s, func() (struct{}, error) { return struct{}{}, r.buf.SkipInt16s(r.r) })
+	return err
+}
+
+func (r *Reader) SkipInt32s() error {
+	_, err := read(r, KindInt32s, func() (struct{}, error) { return struct{}{}, r.buf.SkipInt32s(r.r) })
+	return err
+}
+
+func (r *Reader) SkipInt64s() error {
+	_, err := read(r, KindInt64s, func() (struct{}, error) { return struct{}{}, r.buf.SkipInt64s(r.r) })
+	return err
+}
+
+func (r *Reader) SkipUint16s() error {
+	_, err := read(r, KindUint16s, func() (struct{}, error) { return struct{}{}, r.buf.SkipUint16s(r.r) })
+	return err
+}
+
+func (r *Reader) SkipUint32s() error {
+	_, err := read(r, KindUint32s, func() (struct{}, error) { return struct{}{}, r.buf.SkipUint32s(r.r) })
+	return err
+}
+
+func (r *Reader) SkipUint64s() error {
+	_, err := read(r, KindUint64s, func() (struct{}, error) { return struct{}{}, r.buf.SkipUint64s(r.r) })
+	return err
+}
+
+func (r *Reader) SkipFloat32s() error {
+	_, err := read(r, KindFloat32s, func() (struct{}, error) { return struct{}{}, r.buf.SkipFloat32s(r.r) })
+	return err
+}
+
+func (r *Reader) SkipFloat64s() error {
+	_, err := read(r, KindFloat64s, func() (struct{}, error) { return struct{}{}, r.buf.SkipFloat64s(r.r) })
+	return err
+}
+
+func (r *Reader) SkipComplex64s() error {
+	_, err := read(r, KindComplex64s, func() (struct{}, error) { return struct{}{}, r.buf.SkipComplex64s(r.r) })
+	return err
+}
+
+func (r *Reader) SkipComplex128s() error {
+	_, err := read(r, KindComplex128s, func() (struct{}, error) { return struct{}{}, r.buf.SkipComplex128s(r.r) })
+	return err
+}
+
+func (r *Reader) SkipPackedBools() error {
+	_, err := read(r, KindPackedBools, func() (struct{}, error) { return struct{}{}, r.buf.SkipPackedBools(r.r) })
+	return err
+}
+
+func (r *Reader) SkipTime() error {
+	_, err := read(r, KindTime, func() (struct{}, error) { return struct{}{}, r.buf.SkipTime(r.r) })
+	return err
+}
+
+// Reader returns a Reader reading from r using buf. If stopOnError is
+// true, all reads following a failed read return zero values and the
+// error of the failed read.
+func (buf *Buffer) Reader(r io.Reader, stopOnError ...bool) *Reader {
+	return &Reader{
+		buf:         buf,
+		r:           &countingReader{r: r, max: buf.limits.MaxMessageBytes},
+		stopOnError: len(stopOnError) > 0 && stopOnError[0],
+	}
 }
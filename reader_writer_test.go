@@ -0,0 +1,194 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestReaderStopOnError(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	w := buf.Writer(&b, true)
+	w.WriteInt(42)
+	w.WriteString("hello")
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := buf.Reader(&b, true)
+	i, _ := r.ReadInt()
+	s, _ := r.ReadString()
+	f, _ := r.ReadFloat64()
+	s2, _ := r.ReadString()
+	if i != 42 || s != "hello" {
+		t.Errorf("ReadInt, ReadString = %v, %q, want 42, %q", i, s, "hello")
+	}
+	if f != 0 || s2 != "" {
+		t.Errorf("reads after failure = %v, %q, want zero values", f, s2)
+	}
+	var readErr *ReadError
+	if err := r.Err(); !errors.As(err, &readErr) {
+		t.Fatalf("Err() = %v, want *ReadError", err)
+	}
+	if readErr.Field != 2 || readErr.Offset != 13 {
+		t.Errorf("Err() field, offset = %d, %d, want 2, 13", readErr.Field, readErr.Offset)
+	}
+	if !errors.Is(r.Err(), io.EOF) {
+		t.Errorf("Err() = %v, want %v", r.Err(), io.EOF)
+	}
+}
+
+func TestReaderContinueOnError(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	if err := buf.WriteInt(&b, -5); err != nil {
+		t.Fatal(err)
+	}
+	if err := buf.WriteInt(&b, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	r := buf.Reader(&b)
+	if _, err := r.ReadString(); !errors.Is(err, ErrNegativeLength) {
+		t.Fatalf("ReadString() error = %v, want %v", err, ErrNegativeLength)
+	}
+	if r.Err() == nil {
+		t.Error("Err() after failed read = nil")
+	}
+	if i, err := r.ReadInt(); err != nil || i != 7 {
+		t.Errorf("ReadInt() = %v, %v, want 7, nil", i, err)
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("Err() after successful read = %v, want nil", err)
+	}
+}
+
+func TestWriterBeginMessage(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	w := buf.Writer(&b, true)
+	w.WriteInt(1)
+	msg := w.BeginMessage()
+	msg.WriteString("known")
+	inner := msg.BeginMessage()
+	inner.WriteInt64s(1, 2, 3)
+	if err := inner.End(); err != nil {
+		t.Fatal(err)
+	}
+	msg.WriteString("unknown")
+	msg.WriteFloat64(1.5)
+	if err := msg.End(); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteInt(2)
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := buf.Reader(&b, true)
+	if i, err := r.ReadInt(); err != nil || i != 1 {
+		t.Fatalf("ReadInt() = %v, %v, want 1", i, err)
+	}
+	sub, err := r.SubReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := sub.ReadString(); err != nil || s != "known" {
+		t.Fatalf("ReadString() of sub-message = %q, %v, want %q", s, err, "known")
+	}
+	innerSub, err := sub.SubReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values, err := innerSub.ReadInt64s(); err != nil || len(values) != 3 {
+		t.Fatalf("ReadInt64s() of nested sub-message = %v, %v", values, err)
+	}
+	if err := innerSub.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	// The rest of the sub-message is not understood by this reader.
+	if err := sub.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := r.ReadInt(); err != nil || i != 2 {
+		t.Errorf("ReadInt() after sub-message = %v, %v, want 2", i, err)
+	}
+}
+
+func TestSubReaderBounds(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	w := buf.Writer(&b)
+	msg := w.BeginMessage()
+	msg.WriteInt(1)
+	msg.End()
+	w.WriteString("after")
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := buf.Reader(&b)
+	sub, err := r.SubReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.ReadInt(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.ReadString(); err == nil {
+		t.Error("ReadString() past end of sub-message succeeded")
+	}
+	if err := sub.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := r.ReadString(); err != nil || s != "after" {
+		t.Errorf("ReadString() after sub-message = %q, %v, want %q", s, err, "after")
+	}
+	if err := r.Skip(); err == nil {
+		t.Error("Skip() of a Reader not returned by SubReader succeeded")
+	}
+}
+
+func TestSubReaderTruncated(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	w := buf.Writer(&b)
+	msg := w.BeginMessage()
+	msg.WriteString("truncated")
+	msg.End()
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+	b.Truncate(b.Len() - 2)
+
+	sub, err := buf.Reader(&b).SubReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Skip(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Skip() of truncated sub-message error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestWriterEndError(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	w := buf.Writer(&b)
+	msg := w.BeginMessage()
+	msg.WriteInt(1 << 40)
+	msg.WriteInt(1)
+	if err := msg.End(); err == nil {
+		t.Fatal("End() of failed sub-message succeeded")
+	}
+	if w.Err() == nil {
+		t.Error("Err() of parent after failed sub-message = nil")
+	}
+	if b.Len() != 0 {
+		t.Errorf("Failed sub-message wrote %d bytes", b.Len())
+	}
+	if err := msg.End(); err == nil {
+		t.Error("Second End() succeeded")
+	}
+}
@@ -0,0 +1,125 @@
+package bufrw
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// endOfRecord is the field id that ends a record.
+const endOfRecord = 0
+
+// RecordWriter writes a record of numbered fields, each written as its
+// field id with WriteUvarint, followed by its value as a length-delimited
+// sub-message, as written by Writer.BeginMessage. The record ends with the
+// field id zero.
+//
+// Since readers skip fields with ids they do not know and keep the
+// defaults of fields that are missing, fields can be added to and removed
+// from a record without breaking existing data or readers, as long as the
+// ids of removed fields are not reused.
+type RecordWriter struct {
+	w      *Writer
+	field  *Writer
+	closed bool
+	err    error
+}
+
+// BeginRecord begins a record of numbered fields written to w. Call Close
+// on the returned RecordWriter to end the record.
+func (w *Writer) BeginRecord() *RecordWriter {
+	return &RecordWriter{w: w}
+}
+
+// Field begins the field with the given id, which must be positive, and
+// returns a Writer for its value. The previous field ends when the next
+// field begins or the record is closed. The returned Writer stops on
+// errors, like one returned by Writer.BeginMessage.
+func (rw *RecordWriter) Field(id int) *Writer {
+	rw.endField()
+	if rw.closed || id <= endOfRecord {
+		rw.setErr(rw.w.do(KindInvalid, func() error {
+			if rw.closed {
+				return errors.New("bufrw: Field of a closed RecordWriter")
+			}
+			return fmt.Errorf("bufrw: invalid field id %d", id)
+		}))
+		return rw.w.BeginMessage()
+	}
+	rw.setErr(rw.w.WriteUvarint(uint64(id)))
+	rw.field = rw.w.BeginMessage()
+	return rw.field
+}
+
+// endField ends the current field, if any.
+func (rw *RecordWriter) endField() {
+	if rw.field != nil {
+		rw.setErr(rw.field.End())
+		rw.field = nil
+	}
+}
+
+// setErr records err if it is the first error of the record.
+func (rw *RecordWriter) setErr(err error) {
+	if rw.err == nil {
+		rw.err = err
+	}
+}
+
+// Close ends the last field and the record, returning the first error
+// writing the record.
+func (rw *RecordWriter) Close() error {
+	if rw.closed {
+		return errors.New("bufrw: Close of a closed RecordWriter")
+	}
+	rw.endField()
+	rw.closed = true
+	rw.setErr(rw.w.WriteUvarint(endOfRecord))
+	return rw.err
+}
+
+// RecordReader reads a record of numbered fields written with a
+// RecordWriter.
+type RecordReader struct {
+	r     *Reader
+	field *Reader
+	done  bool
+}
+
+// RecordReader returns a RecordReader for a record read from r.
+func (r *Reader) RecordReader() *RecordReader {
+	return &RecordReader{r: r}
+}
+
+// Next advances to the next field of the record, returning its id and a
+// Reader limited to its value. Any unread remainder of the previous field
+// is skipped, so fields with unknown ids can simply be ignored. At the end
+// of the record, Next returns a zero id and a nil Reader, and r continues
+// after the record.
+func (rr *RecordReader) Next() (int, *Reader, error) {
+	if rr.done {
+		return endOfRecord, nil, nil
+	}
+	if rr.field != nil {
+		err := rr.field.Skip()
+		rr.field = nil
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+	id, err := rr.r.ReadUvarint()
+	if err != nil {
+		return 0, nil, err
+	}
+	if id == endOfRecord {
+		rr.done = true
+		return endOfRecord, nil, nil
+	}
+	if id > math.MaxInt32 {
+		return 0, nil, fmt.Errorf("bufrw: invalid field id %d", id)
+	}
+	if rr.field, err = rr.r.SubReader(); err != nil {
+		return 0, nil, err
+	}
+	return int(id), rr.field, nil
+}
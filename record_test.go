@@ -0,0 +1,178 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// personV1 and personV2 are two versions of a type serialized as a record,
+// where personV2 added the fields Age and Tags.
+type personV1 struct {
+	Name string
+}
+
+func (p *personV1) Serialize() ([]byte, error) { return nil, errors.New("not implemented") }
+func (p *personV1) Deserialize([]byte) error   { return errors.New("not implemented") }
+
+func (p *personV1) SerializeToBufRW(w io.Writer, buf *Buffer) error {
+	rw := buf.Writer(w, true).BeginRecord()
+	rw.Field(1).WriteString(p.Name)
+	return rw.Close()
+}
+
+func (p *personV1) DeserializeFromBufRW(r io.Reader, buf *Buffer) error {
+	rr := buf.Reader(r, true).RecordReader()
+	for {
+		id, field, err := rr.Next()
+		if err != nil || field == nil {
+			return err
+		}
+		if id == 1 {
+			p.Name, err = field.ReadString()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type personV2 struct {
+	Name string
+	Age  int32
+	Tags []string
+}
+
+func (p *personV2) Serialize() ([]byte, error) { return nil, errors.New("not implemented") }
+func (p *personV2) Deserialize([]byte) error   { return errors.New("not implemented") }
+
+func (p *personV2) SerializeToBufRW(w io.Writer, buf *Buffer) error {
+	rw := buf.Writer(w, true).BeginRecord()
+	rw.Field(3).WriteStrings(p.Tags...)
+	rw.Field(1).WriteString(p.Name)
+	rw.Field(2).WriteInt32(p.Age)
+	return rw.Close()
+}
+
+func (p *personV2) DeserializeFromBufRW(r io.Reader, buf *Buffer) error {
+	*p = personV2{Age: -1}
+	rr := buf.Reader(r, true).RecordReader()
+	for {
+		id, field, err := rr.Next()
+		if err != nil || field == nil {
+			return err
+		}
+		switch id {
+		case 1:
+			p.Name, err = field.ReadString()
+		case 2:
+			p.Age, err = field.ReadInt32()
+		case 3:
+			p.Tags, err = field.ReadStrings()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// testRecordCompat writes value followed by a sentinel, reads it into got
+// and checks that the sentinel follows it.
+func testRecordCompat(t *testing.T, value, got SerializableToBufRW) {
+	t.Helper()
+	var buf Buffer
+	var b bytes.Buffer
+	if err := buf.WriteSerializableBufRW(&b, value); err != nil {
+		t.Fatal(err)
+	}
+	if err := buf.WriteInt(&b, 42); err != nil {
+		t.Fatal(err)
+	}
+	if err := buf.ReadSerializableBufRW(&b, got); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := buf.ReadInt(&b); i != 42 || err != nil {
+		t.Errorf("ReadInt() after record = %d, %v, want 42", i, err)
+	}
+}
+
+func TestRecordOldReaderNewData(t *testing.T) {
+	var got personV1
+	testRecordCompat(t, &personV2{Name: "name", Age: 30, Tags: []string{"a"}}, &got)
+	if want := (personV1{Name: "name"}); got != want {
+		t.Errorf("Read = %+v, want %+v", got, want)
+	}
+}
+
+func TestRecordNewReaderOldData(t *testing.T) {
+	var got personV2
+	testRecordCompat(t, &personV1{Name: "name"}, &got)
+	if want := (personV2{Name: "name", Age: -1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Read = %+v, want %+v", got, want)
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	value := personV2{Name: "name", Age: 30, Tags: []string{"a", "b"}}
+	var got personV2
+	testRecordCompat(t, &value, &got)
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("Read = %+v, want %+v", got, value)
+	}
+}
+
+func TestRecordNested(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	w := buf.Writer(&b, true)
+	rw := w.BeginRecord()
+	inner := rw.Field(1).BeginRecord()
+	inner.Field(1).WriteInt64(7)
+	inner.Close()
+	rw.Field(2).WriteBool(true)
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := buf.Reader(&b, true).RecordReader()
+	var ids []int
+	for {
+		id, field, err := rr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if field == nil {
+			break
+		}
+		ids = append(ids, id)
+		if id == 1 {
+			_, inner, err := field.RecordReader().Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i, err := inner.ReadInt64(); i != 7 || err != nil {
+				t.Errorf("ReadInt64() of nested field = %d, %v, want 7", i, err)
+			}
+		}
+	}
+	if !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("Next() ids = %v, want [1 2]", ids)
+	}
+	if b.Len() != 0 {
+		t.Errorf("Read left %d unread bytes", b.Len())
+	}
+}
+
+func TestRecordWriterErrors(t *testing.T) {
+	var buf Buffer
+	rw := buf.Writer(io.Discard).BeginRecord()
+	rw.Field(0).WriteInt(1)
+	if err := rw.Close(); err == nil {
+		t.Error("Close() after Field(0) succeeded")
+	}
+	if err := rw.Close(); err == nil {
+		t.Error("Close() of closed RecordWriter succeeded")
+	}
+}
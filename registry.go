@@ -0,0 +1,394 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+var (
+	// ErrUnsupportedVersion is returned when a versioned value read from
+	// the input has a version that its registered type cannot decode.
+	ErrUnsupportedVersion = errors.New("bufrw: unsupported version")
+
+	// ErrUnknownType is returned when a type id read from the input is not
+	// registered.
+	ErrUnknownType = errors.New("bufrw: unknown type id")
+)
+
+// Registry records the type ids and versions of Serializable types, and
+// how to decode the payloads of their older versions. A Buffer with a
+// registry set by SetRegistry writes values of registered types with
+// WriteSerializable in a versioned envelope, consisting of the type id,
+// the version with WriteUvarint and the serialized value with
+// WriteByteValues. ReadSerializable reads the envelope and decodes the
+// payload of any version into the current type, using the decoders and
+// upgrades registered for older versions.
+//
+// The type id is written as the number given to the type by
+// RegisterNumber with WriteUvarint, or else as a zero followed by the id
+// with WriteString. Numbers keep the encoding compact.
+//
+// The type ids also let WriteRegistered and ReadRegistered write and read
+// values whose concrete type is only known when reading, like encoding/gob
+// does for types registered with gob.Register.
+//
+// Types are registered during initialization, after which a Registry is
+// safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	byID     map[string]*registeredType
+	byNumber map[uint64]*registeredType
+	byType   map[reflect.Type]*registeredType
+}
+
+// registeredType is a type registered with a Registry.
+type registeredType struct {
+	id       string
+	number   uint64
+	typ      reflect.Type
+	version  int
+	decoders map[int]func(r io.Reader, buf *Buffer, val Serializable) error
+	upgrades map[int]upgrade
+}
+
+// upgrade converts values of the type of an older version into values of
+// the next version.
+type upgrade struct {
+	typ reflect.Type
+	fn  func(old Serializable) (Serializable, error)
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		byID:     make(map[string]*registeredType),
+		byNumber: make(map[uint64]*registeredType),
+		byType:   make(map[reflect.Type]*registeredType),
+	}
+}
+
+// SetRegistry sets the registry of the versioned types of the buffer. A
+// nil registry, the default, writes all values without an envelope.
+func (buf *Buffer) SetRegistry(reg *Registry) {
+	buf.registry = reg
+}
+
+// Register registers the current version of the type of val under id,
+// which must not be empty. val must be a pointer, such as a nil *T for a
+// type T whose methods have pointer receivers. Values of the type read by
+// ReadRegistered are created as new(T). Versions are non-negative.
+func (reg *Registry) Register(id string, val Serializable, version int) error {
+	typ := reflect.TypeOf(val)
+	if typ == nil || typ.Kind() != reflect.Pointer {
+		return fmt.Errorf("bufrw: Register of non-pointer type %v", typ)
+	}
+	if id == "" {
+		return errors.New("bufrw: Register with empty type id")
+	}
+	if version < 0 {
+		return fmt.Errorf("bufrw: Register of %q with negative version %d", id, version)
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.byID[id]; ok {
+		return fmt.Errorf("bufrw: type id %q registered twice", id)
+	}
+	if t, ok := reg.byType[typ]; ok {
+		return fmt.Errorf("bufrw: type %v registered as %q and %q", typ, t.id, id)
+	}
+	t := &registeredType{
+		id:       id,
+		typ:      typ,
+		version:  version,
+		decoders: make(map[int]func(io.Reader, *Buffer, Serializable) error),
+		upgrades: make(map[int]upgrade),
+	}
+	reg.byID[id] = t
+	reg.byType[typ] = t
+	return nil
+}
+
+// RegisterNumber gives the type registered under id a positive number,
+// written in place of the id to keep the encoding compact. Readers must
+// give the type the same number.
+func (reg *Registry) RegisterNumber(id string, number int) error {
+	if number <= 0 {
+		return fmt.Errorf("bufrw: RegisterNumber of %q with non-positive number %d", id, number)
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	t, ok := reg.byID[id]
+	if !ok {
+		return fmt.Errorf("bufrw: type id %q not registered", id)
+	}
+	if t.number != 0 {
+		return fmt.Errorf("bufrw: type id %q numbered twice", id)
+	}
+	if other, ok := reg.byNumber[uint64(number)]; ok {
+		return fmt.Errorf("bufrw: number %d given to %q and %q", number, other.id, id)
+	}
+	t.number = uint64(number)
+	reg.byNumber[t.number] = t
+	return nil
+}
+
+// RegisterDecoder registers decode for payloads of an older version of
+// the type registered under id. decode reads the payload from r into val,
+// a value of the current type.
+func (reg *Registry) RegisterDecoder(id string, version int, decode func(r io.Reader, buf *Buffer, val Serializable) error) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	t, err := reg.older(id, version)
+	if err != nil {
+		return err
+	}
+	t.decoders[version] = decode
+	return nil
+}
+
+// RegisterUpgrade registers an upgrade of an older version of the type
+// registered under id. Payloads of the version are read into a new value
+// of the type of old, which must be a pointer, and fn converts the value
+// into one of the type of the next version. Upgrades are chained until
+// the current version is reached, whose type must be the registered type.
+func (reg *Registry) RegisterUpgrade(id string, version int, old Serializable, fn func(old Serializable) (Serializable, error)) error {
+	typ := reflect.TypeOf(old)
+	if typ == nil || typ.Kind() != reflect.Pointer {
+		return fmt.Errorf("bufrw: RegisterUpgrade of non-pointer type %v", typ)
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	t, err := reg.older(id, version)
+	if err != nil {
+		return err
+	}
+	t.upgrades[version] = upgrade{typ: typ, fn: fn}
+	return nil
+}
+
+// older returns the type registered under id, checking that version is
+// one of its older versions.
+func (reg *Registry) older(id string, version int) (*registeredType, error) {
+	t, ok := reg.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("bufrw: type id %q not registered", id)
+	}
+	if version < 0 || version >= t.version {
+		return nil, fmt.Errorf("bufrw: version %d of %q is not older than the current version %d", version, id, t.version)
+	}
+	return t, nil
+}
+
+// lookup returns the registered type of val, or nil if it is not
+// registered or reg is nil.
+func (reg *Registry) lookup(val Serializable) *registeredType {
+	if reg == nil {
+		return nil
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.byType[reflect.TypeOf(val)]
+}
+
+// lookupID returns the type registered under id, or nil if it is not
+// registered or reg is nil.
+func (reg *Registry) lookupID(id string) *registeredType {
+	if reg == nil {
+		return nil
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.byID[id]
+}
+
+// lookupNumber returns the type registered with number, or nil if there
+// is none or reg is nil.
+func (reg *Registry) lookupNumber(number uint64) *registeredType {
+	if reg == nil {
+		return nil
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.byNumber[number]
+}
+
+// WriteRegistered writes val, whose type must be registered with the
+// registry of the buffer, in a versioned envelope, so that it can be read
+// by ReadRegistered without knowing its type. A nil val or nil pointer is
+// written as an empty type id, which ReadRegistered reads as nil.
+func (buf *Buffer) WriteRegistered(w io.Writer, val Serializable) error {
+	if v := reflect.ValueOf(val); val == nil || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return buf.writeTypeID(w, nil)
+	}
+	t := buf.registry.lookup(val)
+	if t == nil {
+		return &UnsupportedTypeError{reflect.TypeOf(val)}
+	}
+	return buf.writeVersioned(w, t, val)
+}
+
+// ReadRegistered reads a value from r, where r reads from a source that
+// has used WriteRegistered, or WriteSerializable with a registered type,
+// to write the value. The value is created from the type registered under
+// the type id read, failing with ErrUnknownType if there is none.
+func (buf *Buffer) ReadRegistered(r io.Reader) (Serializable, error) {
+	t, err := buf.readTypeID(r)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	val := reflect.New(t.typ.Elem()).Interface().(Serializable)
+	if err := buf.readPayload(r, t, val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// writeTypeID writes the type id of the registered type t, or an empty id
+// if t is nil.
+func (buf *Buffer) writeTypeID(w io.Writer, t *registeredType) error {
+	if t != nil && t.number != 0 {
+		return buf.WriteUvarint(w, t.number)
+	}
+	if err := buf.WriteUvarint(w, 0); err != nil {
+		return err
+	}
+	if t == nil {
+		return buf.WriteString(w, "")
+	}
+	return buf.WriteString(w, t.id)
+}
+
+// readTypeID reads a type id, returning the type registered under it, or
+// nil if the id is empty.
+func (buf *Buffer) readTypeID(r io.Reader) (*registeredType, error) {
+	number, err := buf.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if number != 0 {
+		t := buf.registry.lookupNumber(number)
+		if t == nil {
+			return nil, fmt.Errorf("bufrw: type number %d: %w", number, ErrUnknownType)
+		}
+		return t, nil
+	}
+	id, err := buf.ReadString(r)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	t := buf.registry.lookupID(id)
+	if t == nil {
+		return nil, fmt.Errorf("bufrw: type id %q: %w", id, ErrUnknownType)
+	}
+	return t, nil
+}
+
+// writeVersioned writes val, a value of the registered type t, in a
+// versioned envelope.
+func (buf *Buffer) writeVersioned(w io.Writer, t *registeredType, val Serializable) error {
+	var payload []byte
+	if s, ok := val.(SerializableToBufRW); ok {
+		var b bytes.Buffer
+		if err := s.SerializeToBufRW(&b, buf); err != nil {
+			return err
+		}
+		payload = b.Bytes()
+	} else {
+		var err error
+		if payload, err = val.Serialize(); err != nil {
+			return err
+		}
+	}
+	if err := buf.writeTypeID(w, t); err != nil {
+		return err
+	}
+	if err := buf.WriteUvarint(w, uint64(t.version)); err != nil {
+		return err
+	}
+	return buf.WriteByteValues(w, payload...)
+}
+
+// readVersioned reads a versioned envelope into val, a value of the
+// registered type t.
+func (buf *Buffer) readVersioned(r io.Reader, t *registeredType, val Serializable) error {
+	read, err := buf.readTypeID(r)
+	if err != nil {
+		return err
+	}
+	if read != t {
+		id := ""
+		if read != nil {
+			id = read.id
+		}
+		return fmt.Errorf("bufrw: read type id %q into %v registered as %q", id, t.typ, t.id)
+	}
+	return buf.readPayload(r, t, val)
+}
+
+// readPayload reads the version and payload of a versioned envelope
+// following its type id into val, a value of the registered type t.
+func (buf *Buffer) readPayload(r io.Reader, t *registeredType, val Serializable) error {
+	version, err := buf.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	payload, err := buf.ReadByteValues(r)
+	if err != nil {
+		return err
+	}
+	if err := buf.enter(); err != nil {
+		return err
+	}
+	defer buf.leave()
+	return buf.decodeVersion(t, version, payload, val)
+}
+
+// decodeVersion decodes the payload of the given version of the
+// registered type t into val.
+func (buf *Buffer) decodeVersion(t *registeredType, version uint64, payload []byte, val Serializable) error {
+	if version > uint64(t.version) {
+		return fmt.Errorf("bufrw: version %d of %q newer than %d: %w", version, t.id, t.version, ErrUnsupportedVersion)
+	}
+	v := int(version)
+	if v == t.version {
+		return buf.deserialize(payload, val)
+	}
+	if decode, ok := t.decoders[v]; ok {
+		return decode(bytes.NewReader(payload), buf, val)
+	}
+	up, ok := t.upgrades[v]
+	if !ok {
+		return fmt.Errorf("bufrw: version %d of %q: %w", v, t.id, ErrUnsupportedVersion)
+	}
+	old := reflect.New(up.typ.Elem()).Interface().(Serializable)
+	if err := buf.deserialize(payload, old); err != nil {
+		return err
+	}
+	for ; v < t.version; v++ {
+		up, ok := t.upgrades[v]
+		if !ok || reflect.TypeOf(old) != up.typ {
+			return fmt.Errorf("bufrw: no upgrade of %v from version %d of %q: %w", reflect.TypeOf(old), v, t.id, ErrUnsupportedVersion)
+		}
+		var err error
+		if old, err = up.fn(old); err != nil {
+			return err
+		}
+	}
+	if reflect.TypeOf(old) != t.typ || reflect.ValueOf(old).IsNil() {
+		return fmt.Errorf("bufrw: upgrade of %q to version %d returned %T, want %v", t.id, t.version, old, t.typ)
+	}
+	reflect.ValueOf(val).Elem().Set(reflect.ValueOf(old).Elem())
+	return nil
+}
+
+// deserialize decodes the serialized form b of val.
+func (buf *Buffer) deserialize(b []byte, val Serializable) error {
+	if s, ok := val.(SerializableToBufRW); ok {
+		return s.DeserializeFromBufRW(bytes.NewReader(b), buf)
+	}
+	return val.Deserialize(b)
+}
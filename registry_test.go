@@ -0,0 +1,351 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// profileV1, profileV2 and profileV3 are three versions of a type
+// registered as "profile", where profileV2 split the name of profileV1
+// and profileV3 added an age.
+type profileV1 struct {
+	Name string
+}
+
+func (p *profileV1) Serialize() ([]byte, error) { return []byte(p.Name), nil }
+func (p *profileV1) Deserialize(b []byte) error { p.Name = string(b); return nil }
+
+type profileV2 struct {
+	First, Last string
+}
+
+func (p *profileV2) Serialize() ([]byte, error) { return nil, errors.New("not implemented") }
+func (p *profileV2) Deserialize([]byte) error   { return errors.New("not implemented") }
+
+func (p *profileV2) SerializeToBufRW(w io.Writer, buf *Buffer) error {
+	if err := buf.WriteString(w, p.First); err != nil {
+		return err
+	}
+	return buf.WriteString(w, p.Last)
+}
+
+func (p *profileV2) DeserializeFromBufRW(r io.Reader, buf *Buffer) error {
+	var err error
+	if p.First, err = buf.ReadString(r); err != nil {
+		return err
+	}
+	p.Last, err = buf.ReadString(r)
+	return err
+}
+
+type profileV3 struct {
+	First, Last string
+	Age         int32
+}
+
+func (p *profileV3) Serialize() ([]byte, error) { return nil, errors.New("not implemented") }
+func (p *profileV3) Deserialize([]byte) error   { return errors.New("not implemented") }
+
+func (p *profileV3) SerializeToBufRW(w io.Writer, buf *Buffer) error {
+	w2 := buf.Writer(w, true)
+	w2.WriteString(p.First)
+	w2.WriteString(p.Last)
+	return w2.WriteInt32(p.Age)
+}
+
+func (p *profileV3) DeserializeFromBufRW(r io.Reader, buf *Buffer) error {
+	r2 := buf.Reader(r, true)
+	p.First, _ = r2.ReadString()
+	p.Last, _ = r2.ReadString()
+	p.Age, _ = r2.ReadInt32()
+	return r2.Err()
+}
+
+// newProfileRegistry returns a registry with the given version of the
+// profile type registered as the current version.
+func newProfileRegistry(t *testing.T, val Serializable, version int) *Registry {
+	t.Helper()
+	reg := NewRegistry()
+	if err := reg.Register("profile", val, version); err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+// writeProfile writes val with a buffer using reg.
+func writeProfile(t *testing.T, reg *Registry, val Serializable) *bytes.Buffer {
+	t.Helper()
+	var buf Buffer
+	buf.SetRegistry(reg)
+	var b bytes.Buffer
+	if err := buf.WriteSerializable(&b, val); err != nil {
+		t.Fatal(err)
+	}
+	return &b
+}
+
+func TestRegistryUpgrade(t *testing.T) {
+	reg := newProfileRegistry(t, (*profileV3)(nil), 3)
+	err := reg.RegisterUpgrade("profile", 1, (*profileV1)(nil), func(old Serializable) (Serializable, error) {
+		first, last, _ := strings.Cut(old.(*profileV1).Name, " ")
+		return &profileV2{first, last}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = reg.RegisterUpgrade("profile", 2, (*profileV2)(nil), func(old Serializable) (Serializable, error) {
+		v2 := old.(*profileV2)
+		return &profileV3{First: v2.First, Last: v2.Last, Age: -1}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		b    *bytes.Buffer
+		want profileV3
+	}{
+		{writeProfile(t, newProfileRegistry(t, (*profileV1)(nil), 1), &profileV1{"Ada Lovelace"}), profileV3{"Ada", "Lovelace", -1}},
+		{writeProfile(t, newProfileRegistry(t, (*profileV2)(nil), 2), &profileV2{"Alan", "Turing"}), profileV3{"Alan", "Turing", -1}},
+		{writeProfile(t, reg, &profileV3{"Grace", "Hopper", 85}), profileV3{"Grace", "Hopper", 85}},
+	}
+	for _, test := range tests {
+		var buf Buffer
+		buf.SetRegistry(reg)
+		var got profileV3
+		if err := buf.Reader(test.b).ReadSerializable(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("ReadSerializable() = %+v, want %+v", got, test.want)
+		}
+	}
+}
+
+func TestRegistryDecoder(t *testing.T) {
+	reg := newProfileRegistry(t, (*profileV3)(nil), 3)
+	err := reg.RegisterDecoder("profile", 1, func(r io.Reader, buf *Buffer, val Serializable) error {
+		b, err := io.ReadAll(r)
+		val.(*profileV3).First = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := writeProfile(t, newProfileRegistry(t, (*profileV1)(nil), 1), &profileV1{"Ada"})
+	var buf Buffer
+	buf.SetRegistry(reg)
+	var got profileV3
+	if err := buf.ReadSerializable(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (profileV3{First: "Ada"}); got != want {
+		t.Errorf("ReadSerializable() = %+v, want %+v", got, want)
+	}
+
+	// Version 2 has neither a decoder nor an upgrade.
+	b = writeProfile(t, newProfileRegistry(t, (*profileV2)(nil), 2), &profileV2{"Alan", "Turing"})
+	if err := buf.ReadSerializable(b, &got); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("ReadSerializable() of version 2 error is %v, want %v", err, ErrUnsupportedVersion)
+	}
+}
+
+func TestRegistryNewerVersion(t *testing.T) {
+	b := writeProfile(t, newProfileRegistry(t, (*profileV3)(nil), 3), &profileV3{"Grace", "Hopper", 85})
+	var buf Buffer
+	buf.SetRegistry(newProfileRegistry(t, (*profileV2)(nil), 2))
+	var got profileV2
+	if err := buf.ReadSerializable(b, &got); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("ReadSerializable() of newer version error is %v, want %v", err, ErrUnsupportedVersion)
+	}
+}
+
+func TestRegistryRegisterErrors(t *testing.T) {
+	reg := newProfileRegistry(t, (*profileV2)(nil), 2)
+	if err := reg.Register("profile", (*profileV3)(nil), 3); err == nil {
+		t.Error("Register() of duplicate id succeeded")
+	}
+	if err := reg.Register("other", (*profileV2)(nil), 1); err == nil {
+		t.Error("Register() of duplicate type succeeded")
+	}
+	if err := reg.RegisterDecoder("profile", 2, nil); err == nil {
+		t.Error("RegisterDecoder() of current version succeeded")
+	}
+	if err := reg.RegisterDecoder("unknown", 1, nil); err == nil {
+		t.Error("RegisterDecoder() of unknown id succeeded")
+	}
+}
+
+// shape is an interface implemented by the registered types circle and
+// rect.
+type shape interface {
+	Serializable
+	area() float64
+}
+
+type circle struct {
+	R float64
+}
+
+func (c *circle) area() float64 { return 3 * c.R * c.R }
+
+func (c *circle) Serialize() ([]byte, error) { return nil, errors.New("not implemented") }
+func (c *circle) Deserialize([]byte) error   { return errors.New("not implemented") }
+
+func (c *circle) SerializeToBufRW(w io.Writer, buf *Buffer) error {
+	return buf.WriteFloat64(w, c.R)
+}
+
+func (c *circle) DeserializeFromBufRW(r io.Reader, buf *Buffer) error {
+	var err error
+	c.R, err = buf.ReadFloat64(r)
+	return err
+}
+
+type rect struct {
+	W, H float64
+}
+
+func (r *rect) area() float64 { return r.W * r.H }
+
+func (r *rect) Serialize() ([]byte, error) { return nil, errors.New("not implemented") }
+func (r *rect) Deserialize([]byte) error   { return errors.New("not implemented") }
+
+func (r *rect) SerializeToBufRW(w io.Writer, buf *Buffer) error {
+	return buf.Writer(w).WriteFloat64s(r.W, r.H)
+}
+
+func (r *rect) DeserializeFromBufRW(rd io.Reader, buf *Buffer) error {
+	v, err := buf.ReadFloat64s(rd)
+	if err == nil && len(v) != 2 {
+		err = errors.New("invalid rect")
+	}
+	if err != nil {
+		return err
+	}
+	r.W, r.H = v[0], v[1]
+	return nil
+}
+
+// newShapeRegistry returns a registry with circle and rect registered,
+// and rect numbered.
+func newShapeRegistry(t *testing.T) *Registry {
+	t.Helper()
+	reg := NewRegistry()
+	if err := reg.Register("circle", (*circle)(nil), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register("rect", (*rect)(nil), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.RegisterNumber("rect", 1); err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+func TestBufferReadWriteRegistered(t *testing.T) {
+	var buf Buffer
+	buf.SetRegistry(newShapeRegistry(t))
+	var b bytes.Buffer
+	shapes := []shape{&circle{1}, &rect{2, 3}, nil, (*circle)(nil)}
+	err := WriteSlice(&buf, &b, shapes, func(buf *Buffer, w io.Writer, s shape) error {
+		return buf.WriteRegistered(w, s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSlice(&buf, &b, func(buf *Buffer, r io.Reader) (shape, error) {
+		val, err := buf.ReadRegistered(r)
+		s, _ := val.(shape)
+		return s, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []shape{&circle{1}, &rect{2, 3}, nil, nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadRegistered() = %v, want %v", got, want)
+	}
+}
+
+func TestReaderWriterRegistered(t *testing.T) {
+	for _, tagged := range []bool{false, true} {
+		var buf Buffer
+		buf.SetRegistry(newShapeRegistry(t))
+		var b bytes.Buffer
+		w := buf.Writer(&b, true)
+		r := buf.Reader(&b, true)
+		if tagged {
+			w, r = buf.TaggedWriter(&b, true), buf.TaggedReader(&b, true)
+		}
+		w.WriteRegistered(&circle{1})
+		if err := w.WriteRegistered(&rect{2, 3}); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []shape{&circle{1}, &rect{2, 3}} {
+			if got, err := r.ReadRegistered(); err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadRegistered() with tagged %t = %v, %v, want %v", tagged, got, err, want)
+			}
+		}
+	}
+}
+
+func TestRegistryNumber(t *testing.T) {
+	var buf Buffer
+	buf.SetRegistry(newShapeRegistry(t))
+	var named, numbered bytes.Buffer
+	if err := buf.WriteRegistered(&named, &circle{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := buf.WriteRegistered(&numbered, &rect{2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	// A numbered type is written with its number, a named type with a zero
+	// followed by its id.
+	if got, want := numbered.Bytes()[0], byte(1); got != want {
+		t.Errorf("WriteRegistered() of numbered type wrote id %d, want %d", got, want)
+	}
+	if got, want := named.Bytes()[0], byte(0); got != want {
+		t.Errorf("WriteRegistered() of named type wrote %d, want %d", got, want)
+	}
+
+	reg := newShapeRegistry(t)
+	if err := reg.RegisterNumber("circle", 1); err == nil {
+		t.Error("RegisterNumber() of duplicate number succeeded")
+	}
+	if err := reg.RegisterNumber("rect", 2); err == nil {
+		t.Error("RegisterNumber() of numbered type succeeded")
+	}
+	if err := reg.RegisterNumber("circle", 0); err == nil {
+		t.Error("RegisterNumber() of zero succeeded")
+	}
+	if err := reg.RegisterNumber("unknown", 2); err == nil {
+		t.Error("RegisterNumber() of unknown id succeeded")
+	}
+}
+
+func TestBufferReadWriteRegisteredUnknown(t *testing.T) {
+	var buf Buffer
+	var unsupported *UnsupportedTypeError
+	if err := buf.WriteRegistered(io.Discard, &circle{1}); !errors.As(err, &unsupported) {
+		t.Errorf("WriteRegistered() of unregistered type error is %v, want %T", err, unsupported)
+	}
+	// Both the named circle and the numbered rect are unknown to a buffer
+	// without a registry.
+	for _, val := range []shape{&circle{1}, &rect{2, 3}} {
+		var b bytes.Buffer
+		buf.SetRegistry(newShapeRegistry(t))
+		if err := buf.WriteRegistered(&b, val); err != nil {
+			t.Fatal(err)
+		}
+		buf.SetRegistry(nil)
+		if _, err := buf.ReadRegistered(&b); !errors.Is(err, ErrUnknownType) {
+			t.Errorf("ReadRegistered() of unknown %T error is %v, want %v", val, err, ErrUnknownType)
+		}
+	}
+}
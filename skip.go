@@ -0,0 +1,334 @@
+package bufrw
+
+import (
+	"fmt"
+	"io"
+)
+
+// Kind identifies the encoding of a value written by a Buffer, such as
+// KindString for values written with WriteString. It lets readers skip
+// values they do not decode. Kinds are written in the tags of values
+// written by TaggedWriter, so their values never change.
+type Kind uint8
+
+const (
+	KindInvalid Kind = iota
+	KindBool
+	KindInt
+	KindInt8
+	KindInt16
+	KindInt32
+	KindInt64
+	KindUint8 // WriteUint8 or WriteByteValue
+	KindUint16
+	KindUint32
+	KindUint64
+	KindFloat32
+	KindFloat64
+	KindComplex64
+	KindComplex128
+	KindUvarint
+	KindVarint
+	KindString // WriteString or WriteNullableString
+	KindTime
+	KindDuration
+	KindBools
+	KindInts
+	KindInt8s
+	KindInt16s
+	KindInt32s
+	KindInt64s
+	KindByteValues // WriteByteValues, WriteUint8s or WriteSerializable
+	KindUint16s
+	KindUint32s
+	KindUint64s
+	KindFloat32s
+	KindFloat64s
+	KindComplex64s
+	KindComplex128s
+	KindStrings
+	KindPackedBools
+	KindBitset
+	KindMessage // Writer.BeginMessage
+)
+
+var kindNames = [...]string{
+	KindInvalid:     "Invalid",
+	KindBool:        "Bool",
+	KindInt:         "Int",
+	KindInt8:        "Int8",
+	KindInt16:       "Int16",
+	KindInt32:       "Int32",
+	KindInt64:       "Int64",
+	KindUint8:       "Uint8",
+	KindUint16:      "Uint16",
+	KindUint32:      "Uint32",
+	KindUint64:      "Uint64",
+	KindFloat32:     "Float32",
+	KindFloat64:     "Float64",
+	KindComplex64:   "Complex64",
+	KindComplex128:  "Complex128",
+	KindUvarint:     "Uvarint",
+	KindVarint:      "Varint",
+	KindString:      "String",
+	KindTime:        "Time",
+	KindDuration:    "Duration",
+	KindBools:       "Bools",
+	KindInts:        "Ints",
+	KindInt8s:       "Int8s",
+	KindInt16s:      "Int16s",
+	KindInt32s:      "Int32s",
+	KindInt64s:      "Int64s",
+	KindByteValues:  "ByteValues",
+	KindUint16s:     "Uint16s",
+	KindUint32s:     "Uint32s",
+	KindUint64s:     "Uint64s",
+	KindFloat32s:    "Float32s",
+	KindFloat64s:    "Float64s",
+	KindComplex64s:  "Complex64s",
+	KindComplex128s: "Complex128s",
+	KindStrings:     "Strings",
+	KindPackedBools: "PackedBools",
+	KindBitset:      "Bitset",
+	KindMessage:     "Message",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", uint8(k))
+}
+
+// size returns the number of bytes of a value of kind k, or of each
+// element of a plural kind, or zero if the size varies.
+func (k Kind) size() int {
+	switch k {
+	case KindBool, KindInt8, KindUint8, KindBools, KindInt8s, KindByteValues:
+		return 1
+	case KindInt16, KindUint16, KindInt16s, KindUint16s:
+		return 2
+	case KindInt, KindInt32, KindUint32, KindFloat32, KindInts, KindInt32s, KindUint32s, KindFloat32s:
+		return 4
+	case KindInt64, KindUint64, KindFloat64, KindComplex64, KindDuration, KindInt64s, KindUint64s, KindFloat64s, KindComplex64s:
+		return 8
+	case KindComplex128, KindComplex128s:
+		return 16
+	}
+	return 0
+}
+
+// Skip reads past a value of the given kind from r without decoding it,
+// where r reads from a source that has used the Write method matching the
+// kind to write the value. The skipped bytes are discarded with Seek if r
+// implements io.Seeker, and otherwise read and discarded. When seeking,
+// skipping past the end of the input is only detected by the next read.
+func (buf *Buffer) Skip(r io.Reader, kind Kind) error {
+	switch kind {
+	case KindBool, KindInt, KindInt8, KindInt16, KindInt32, KindInt64, KindUint8, KindUint16, KindUint32, KindUint64,
+		KindFloat32, KindFloat64, KindComplex64, KindComplex128, KindDuration:
+		return discard(r, int64(kind.size()))
+	case KindBools, KindInts, KindInt8s, KindInt16s, KindInt32s, KindInt64s, KindByteValues, KindUint16s, KindUint32s, KindUint64s,
+		KindFloat32s, KindFloat64s, KindComplex64s, KindComplex128s:
+		return buf.skipValues(r, kind.size())
+	case KindUvarint, KindVarint:
+		_, err := buf.ReadUvarint(r)
+		return err
+	case KindString:
+		return buf.SkipString(r)
+	case KindTime:
+		return buf.SkipTime(r)
+	case KindStrings:
+		return buf.SkipStrings(r)
+	case KindPackedBools, KindBitset:
+		return buf.SkipPackedBools(r)
+	case KindMessage:
+		return buf.skipBytes(r, 0)
+	}
+	return fmt.Errorf("bufrw: cannot skip kind %v", kind)
+}
+
+// SkipString reads past a string value written with WriteString or
+// WriteNullableString without allocating it.
+func (buf *Buffer) SkipString(r io.Reader) error {
+	return buf.skipBytes(r, buf.limits.MaxStringLen)
+}
+
+// SkipStrings reads past string values written with WriteStrings without
+// allocating them.
+func (buf *Buffer) SkipStrings(r io.Reader) error {
+	n, err := buf.readNullableLen(r, buf.limits.MaxSliceLen, 1)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := buf.SkipString(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SkipByteValues reads past byte values written with WriteByteValues,
+// WriteUint8s or WriteSerializable without allocating them.
+func (buf *Buffer) SkipByteValues(r io.Reader) error {
+	return buf.skipValues(r, 1)
+}
+
+// SkipBools reads past boolean values written with WriteBools.
+func (buf *Buffer) SkipBools(r io.Reader) error {
+	return buf.skipValues(r, 1)
+}
+
+// SkipInts reads past int values written with WriteInts.
+func (buf *Buffer) SkipInts(r io.Reader) error {
+	return buf.skipValues(r, 4)
+}
+
+// SkipInt8s reads past int8 values written with WriteInt8s.
+func (buf *Buffer) SkipInt8s(r io.Reader) error {
+	return buf.skipValues(r, 1)
+}
+
+// SkipInt16s reads past int16 values written with WriteInt16s.
+func (buf *Buffer) SkipInt16s(r io.Reader) error {
+	return buf.skipValues(r, 2)
+}
+
+// SkipInt32s reads past int32 values written with WriteInt32s.
+func (buf *Buffer) SkipInt32s(r io.Reader) error {
+	return buf.skipValues(r, 4)
+}
+
+// SkipInt64s reads past int64 values written with WriteInt64s.
+func (buf *Buffer) SkipInt64s(r io.Reader) error {
+	return buf.skipValues(r, 8)
+}
+
+// SkipUint16s reads past uint16 values written with WriteUint16s.
+func (buf *Buffer) SkipUint16s(r io.Reader) error {
+	return buf.skipValues(r, 2)
+}
+
+// SkipUint32s reads past uint32 values written with WriteUint32s.
+func (buf *Buffer) SkipUint32s(r io.Reader) error {
+	return buf.skipValues(r, 4)
+}
+
+// SkipUint64s reads past uint64 values written with WriteUint64s.
+func (buf *Buffer) SkipUint64s(r io.Reader) error {
+	return buf.skipValues(r, 8)
+}
+
+// SkipFloat32s reads past float32 values written with WriteFloat32s.
+func (buf *Buffer) SkipFloat32s(r io.Reader) error {
+	return buf.skipValues(r, 4)
+}
+
+// SkipFloat64s reads past float64 values written with WriteFloat64s.
+func (buf *Buffer) SkipFloat64s(r io.Reader) error {
+	return buf.skipValues(r, 8)
+}
+
+// SkipComplex64s reads past complex64 values written with
+// WriteComplex64s.
+func (buf *Buffer) SkipComplex64s(r io.Reader) error {
+	return buf.skipValues(r, 8)
+}
+
+// SkipComplex128s reads past complex128 values written with
+// WriteComplex128s.
+func (buf *Buffer) SkipComplex128s(r io.Reader) error {
+	return buf.skipValues(r, 16)
+}
+
+// SkipPackedBools reads past boolean values written with WritePackedBools,
+// or the bits of a bitset written with WriteBitset.
+func (buf *Buffer) SkipPackedBools(r io.Reader) error {
+	n, err := buf.readNullableLen(r, buf.limits.MaxSliceLen, 0)
+	if err != nil || n == nilLen {
+		return err
+	}
+	return discard(r, int64(n+7)/8)
+}
+
+// SkipTime reads past a time.Time value written with WriteTime, using the
+// time mode of the buffer.
+func (buf *Buffer) SkipTime(r io.Reader) error {
+	if buf.timeMode == TimeUnixNano {
+		return discard(r, 8)
+	}
+	if err := discard(r, 12); err != nil {
+		return err
+	}
+	if buf.timeMode != TimeLocation {
+		return nil
+	}
+	if err := buf.SkipString(r); err != nil {
+		return err
+	}
+	return discard(r, 4)
+}
+
+// skipValues reads past values of size bytes each written with
+// writeValues.
+func (buf *Buffer) skipValues(r io.Reader, size int) error {
+	n, err := buf.readNullableLen(r, buf.limits.MaxSliceLen, size)
+	if err != nil || n == nilLen {
+		return err
+	}
+	return discard(r, int64(n)*int64(size))
+}
+
+// skipBytes reads past a length-prefixed run of at most max bytes.
+func (buf *Buffer) skipBytes(r io.Reader, max int) error {
+	n, err := buf.readNullableLen(r, max, 1)
+	if err != nil || n == nilLen {
+		return err
+	}
+	return discard(r, int64(n))
+}
+
+// discard discards the next n bytes of r, seeking past them if r is an
+// io.Seeker and reading them otherwise. Like io.ReadFull, it returns
+// io.EOF if no bytes could be read and io.ErrUnexpectedEOF if only some
+// could. Counting and limited readers are seen through, so that seeking
+// remains possible underneath them.
+func discard(r io.Reader, n int64) error {
+	if n == 0 {
+		return nil
+	}
+	switch r := r.(type) {
+	case *countingReader:
+		if err := checkRemaining(r, n); err != nil {
+			return err
+		}
+		if err := discard(r.r, n); err != nil {
+			return err
+		}
+		r.n += n
+		return nil
+	case *io.LimitedReader:
+		if r.N <= 0 {
+			return io.EOF
+		}
+		if n > r.N {
+			return io.ErrUnexpectedEOF
+		}
+		if err := discard(r.R, n); err != nil {
+			return err
+		}
+		r.N -= n
+		return nil
+	case io.Seeker:
+		// Seeking fails on inputs such as pipes, which are read instead.
+		if _, err := r.Seek(n, io.SeekCurrent); err == nil {
+			return nil
+		}
+	}
+	m, err := io.CopyN(io.Discard, r, n)
+	if err == io.EOF && m > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
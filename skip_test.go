@@ -0,0 +1,169 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBufferSkip(t *testing.T) {
+	writes := []struct {
+		kind  Kind
+		write func(w *Writer)
+	}{
+		{KindBool, func(w *Writer) { w.WriteBool(true) }},
+		{KindInt, func(w *Writer) { w.WriteInt(-1) }},
+		{KindInt8, func(w *Writer) { w.WriteInt8(-1) }},
+		{KindInt16, func(w *Writer) { w.WriteInt16(-1) }},
+		{KindInt32, func(w *Writer) { w.WriteInt32(-1) }},
+		{KindInt64, func(w *Writer) { w.WriteInt64(-1) }},
+		{KindUint8, func(w *Writer) { w.WriteByteValue(1) }},
+		{KindUint16, func(w *Writer) { w.WriteUint16(1) }},
+		{KindUint32, func(w *Writer) { w.WriteUint32(1) }},
+		{KindUint64, func(w *Writer) { w.WriteUint64(1) }},
+		{KindFloat32, func(w *Writer) { w.WriteFloat32(1.5) }},
+		{KindFloat64, func(w *Writer) { w.WriteFloat64(1.5) }},
+		{KindComplex64, func(w *Writer) { w.WriteComplex64(1 + 2i) }},
+		{KindComplex128, func(w *Writer) { w.WriteComplex128(1 + 2i) }},
+		{KindUvarint, func(w *Writer) { w.WriteUvarint(1 << 40) }},
+		{KindVarint, func(w *Writer) { w.WriteVarint(-1 << 40) }},
+		{KindString, func(w *Writer) { w.WriteString("hello") }},
+		{KindString, func(w *Writer) { w.WriteNullableString(nil) }},
+		{KindTime, func(w *Writer) { w.WriteTime(time.Unix(1, 2)) }},
+		{KindDuration, func(w *Writer) { w.WriteDuration(time.Second) }},
+		{KindBools, func(w *Writer) { w.WriteBools(true, false) }},
+		{KindInts, func(w *Writer) { w.WriteInts(1, 2, 3) }},
+		{KindInt8s, func(w *Writer) { w.WriteInt8s(1, 2, 3) }},
+		{KindInt16s, func(w *Writer) { w.WriteInt16s(1, 2, 3) }},
+		{KindInt32s, func(w *Writer) { w.WriteInt32s(1, 2, 3) }},
+		{KindInt64s, func(w *Writer) { w.WriteInt64s(1, 2, 3) }},
+		{KindByteValues, func(w *Writer) { w.WriteByteValues(1, 2, 3) }},
+		{KindByteValues, func(w *Writer) { w.WriteUint8s(1, 2, 3) }},
+		{KindUint16s, func(w *Writer) { w.WriteUint16s(1, 2, 3) }},
+		{KindUint32s, func(w *Writer) { w.WriteUint32s(1, 2, 3) }},
+		{KindUint64s, func(w *Writer) { w.WriteUint64s(1, 2, 3) }},
+		{KindFloat32s, func(w *Writer) { w.WriteFloat32s(1, 2, 3) }},
+		{KindFloat64s, func(w *Writer) { w.WriteFloat64s(1, 2, 3) }},
+		{KindComplex64s, func(w *Writer) { w.WriteComplex64s(1, 2i) }},
+		{KindComplex128s, func(w *Writer) { w.WriteComplex128s(1, 2i) }},
+		{KindStrings, func(w *Writer) { w.WriteStrings("a", "", "abc") }},
+		{KindPackedBools, func(w *Writer) { w.WritePackedBools(true, false, true) }},
+		{KindBitset, func(w *Writer) { w.WriteBitset(1, 2) }},
+		{KindMessage, func(w *Writer) {
+			m := w.BeginMessage()
+			m.WriteString("nested")
+			m.End()
+		}},
+	}
+	for _, nullable := range []bool{false, true} {
+		for _, test := range writes {
+			var buf Buffer
+			buf.SetNullable(nullable)
+			var b bytes.Buffer
+			w := buf.Writer(&b)
+			test.write(w)
+			w.WriteInt(42)
+			if err := w.Err(); err != nil {
+				t.Fatal(err)
+			}
+			data := b.Bytes()
+			// bytes.Reader is skipped with Seek, bytes.Buffer by reading.
+			for _, r := range []io.Reader{bytes.NewReader(data), bytes.NewBuffer(data)} {
+				if err := buf.Skip(r, test.kind); err != nil {
+					t.Fatalf("Skip(%T, %v) error: %v", r, test.kind, err)
+				}
+				if i, err := buf.ReadInt(r); i != 42 || err != nil {
+					t.Errorf("ReadInt() after Skip(%T, %v) = %d, %v, want 42", r, test.kind, i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestBufferSkipNamed(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	w := buf.Writer(&b)
+	w.WriteString("hello")
+	w.WriteInts(1, 2, 3)
+	w.WriteByteValues(1, 2)
+	w.WriteStrings("a", "b")
+	w.WriteFloat64s(1.5)
+	w.WriteInt(42)
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewBuffer(b.Bytes())
+	for _, skip := range []func(*Buffer, io.Reader) error{
+		(*Buffer).SkipString,
+		(*Buffer).SkipInts,
+		(*Buffer).SkipByteValues,
+		(*Buffer).SkipStrings,
+		(*Buffer).SkipFloat64s,
+	} {
+		if err := skip(&buf, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if i, err := buf.ReadInt(r); i != 42 || err != nil {
+		t.Errorf("ReadInt() after skips = %d, %v, want 42", i, err)
+	}
+}
+
+func TestBufferSkipTruncated(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	if err := buf.WriteInt64s(&b, 1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()[:b.Len()-1]
+	if err := buf.SkipInt64s(bytes.NewBuffer(data)); err != io.ErrUnexpectedEOF {
+		t.Errorf("SkipInt64s() of truncated input = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if err := buf.Skip(bytes.NewBuffer(nil), KindInt64); err != io.EOF {
+		t.Errorf("Skip() of empty input = %v, want %v", err, io.EOF)
+	}
+	if err := buf.Skip(bytes.NewBuffer(data), KindInvalid); err == nil {
+		t.Error("Skip(KindInvalid) succeeded")
+	}
+}
+
+func TestBufferSkipLimits(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	if err := buf.WriteString(&b, "too long"); err != nil {
+		t.Fatal(err)
+	}
+	buf.SetLimits(Limits{MaxStringLen: 4})
+	if err := buf.SkipString(&b); !errors.Is(err, ErrLengthExceeded) {
+		t.Errorf("SkipString() error is %v, want %v", err, ErrLengthExceeded)
+	}
+}
+
+func TestReaderSkip(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	w := buf.Writer(&b)
+	w.WriteStrings("a", "b")
+	w.WriteFloat64s(1, 2)
+	w.WriteInt(42)
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+	buf.SetLimits(Limits{MaxMessageBytes: int64(b.Len())})
+	r := buf.Reader(bytes.NewReader(b.Bytes()))
+	r.SkipStrings()
+	r.SkipKind(KindFloat64s)
+	if i, err := r.ReadInt(); i != 42 || err != nil {
+		t.Errorf("ReadInt() after skips = %d, %v, want 42", i, err)
+	}
+	if err := r.SkipKind(KindInt); !errors.Is(err, ErrLengthExceeded) {
+		t.Errorf("SkipKind() past MaxMessageBytes error is %v, want %v", err, ErrLengthExceeded)
+	}
+	var readErr *ReadError
+	if !errors.As(r.Err(), &readErr) || readErr.Field != 3 || readErr.Offset != int64(b.Len()) {
+		t.Errorf("Err() = %v, want field 3 at offset %d", r.Err(), b.Len())
+	}
+}
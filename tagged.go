@@ -0,0 +1,343 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// ErrTagMismatch is returned when a tagged Reader reads a value whose tag
+// has a different kind than the value being read.
+var ErrTagMismatch = errors.New("bufrw: tag does not match the value read")
+
+// fieldBit is set in a tag byte that is followed by a field number.
+const fieldBit = 0x80
+
+// TaggedWriter returns a Writer like Writer, except that each value is
+// preceded by a tag describing its kind, and optionally the field number
+// set with Field, so that the values can be read without a schema by
+// Reader.ReadAny. Values must be read with TaggedReader.
+//
+// A tag is a single byte holding the Kind of the value. If its high bit is
+// set, it is followed by the field number as with WriteUvarint. Values
+// whose encoding does not describe itself, such as those written with
+// WriteSerializable, WriteMarshaler or Marshal, are tagged as KindByteValues
+// holding their encoding.
+func (buf *Buffer) TaggedWriter(w io.Writer, stopOnError ...bool) *Writer {
+	writer := buf.Writer(w, stopOnError...)
+	writer.tagged = true
+	return writer
+}
+
+// TaggedReader returns a Reader like Reader for values written with
+// TaggedWriter. Each read checks the tag of the value against the kind
+// being read, failing with ErrTagMismatch if they differ.
+func (buf *Buffer) TaggedReader(r io.Reader, stopOnError ...bool) *Reader {
+	reader := buf.Reader(r, stopOnError...)
+	reader.tagged = true
+	return reader
+}
+
+// Field sets the field number written in the tag of the next value, and
+// returns w. Field numbers range from 1 to math.MaxInt32, and zero means no
+// field number. Writing the value fails for other numbers. Field has no
+// effect on untagged writers.
+func (w *Writer) Field(n int) *Writer {
+	w.fieldNum = n
+	return w
+}
+
+// writeTag writes the tag of a value of the given kind if w is tagged.
+func (w *Writer) writeTag(kind Kind) error {
+	if !w.tagged || kind == KindInvalid {
+		return nil
+	}
+	field := w.fieldNum
+	w.fieldNum = 0
+	if field < 0 || field > math.MaxInt32 {
+		return fmt.Errorf("bufrw: invalid field number %d", field)
+	}
+	if field == 0 {
+		return w.buf.WriteByteValue(w.w, byte(kind))
+	}
+	if err := w.buf.WriteByteValue(w.w, byte(kind)|fieldBit); err != nil {
+		return err
+	}
+	return w.buf.WriteUvarint(w.w, uint64(field))
+}
+
+// opaque calls fn to write a value whose encoding does not describe
+// itself. Tagged writers write the encoding as byte values.
+func (w *Writer) opaque(fn func(w io.Writer) error) error {
+	if !w.tagged {
+		return w.do(KindInvalid, func() error { return fn(w.w) })
+	}
+	return w.do(KindByteValues, func() error {
+		var b bytes.Buffer
+		if err := fn(&b); err != nil {
+			return err
+		}
+		return w.buf.WriteByteValues(w.w, b.Bytes()...)
+	})
+}
+
+// readOpaque calls fn to read a value written with Writer.opaque.
+func readOpaque[T any](r *Reader, fn func(r io.Reader) (T, error)) (T, error) {
+	return read(r, KindByteValues, func() (T, error) {
+		if !r.tagged {
+			return fn(r.r)
+		}
+		b, err := r.buf.ReadByteValues(r.r)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		return fn(bytes.NewReader(b))
+	})
+}
+
+// lenKind returns the kind of the lengths written by WriteLen.
+func (buf *Buffer) lenKind() Kind {
+	if buf.varintLengths {
+		return KindVarint
+	}
+	return KindInt
+}
+
+// ReadTag reads the tag of the next value, returning its kind and field
+// number, which is zero if the tag has none. The next read uses the tag
+// instead of reading it, so a reader can decide how to read or skip a
+// value from its tag. Calling ReadTag again before the next read returns
+// the same tag.
+func (r *Reader) ReadTag() (Kind, int, error) {
+	if !r.tagged {
+		return KindInvalid, 0, errors.New("bufrw: ReadTag of an untagged Reader")
+	}
+	if r.err != nil && r.stopOnError {
+		return KindInvalid, 0, r.err.Err
+	}
+	offset := r.r.n
+	kind, field, err := r.nextTag()
+	if err != nil {
+		r.err = &ReadError{Field: r.field, Offset: offset, Err: err}
+		return KindInvalid, 0, err
+	}
+	r.hasTag, r.tagKind, r.tagField = true, kind, field
+	return kind, field, nil
+}
+
+// nextTag returns the tag read by ReadTag, or reads the next tag.
+func (r *Reader) nextTag() (Kind, int, error) {
+	if r.hasTag {
+		r.hasTag = false
+		return r.tagKind, r.tagField, nil
+	}
+	b, err := r.buf.ReadByteValue(r.r)
+	if err != nil {
+		return KindInvalid, 0, err
+	}
+	kind := Kind(b &^ fieldBit)
+	if kind == KindInvalid || int(kind) >= len(kindNames) {
+		return KindInvalid, 0, fmt.Errorf("bufrw: invalid tag %#x", b)
+	}
+	if b&fieldBit == 0 {
+		return kind, 0, nil
+	}
+	field, err := r.buf.ReadUvarint(r.r)
+	if err != nil {
+		return KindInvalid, 0, err
+	}
+	if field == 0 || field > math.MaxInt32 {
+		return KindInvalid, 0, fmt.Errorf("bufrw: invalid field number %d", field)
+	}
+	return kind, int(field), nil
+}
+
+// expectTag reads the tag of a value of the given kind if r is tagged,
+// failing with ErrTagMismatch if the tag has another kind.
+func (r *Reader) expectTag(kind Kind) error {
+	if !r.tagged || kind == KindInvalid {
+		return nil
+	}
+	got, _, err := r.nextTag()
+	if err != nil {
+		return err
+	}
+	if got != kind {
+		return fmt.Errorf("bufrw: found %v, want %v: %w", got, kind, ErrTagMismatch)
+	}
+	return nil
+}
+
+// Value is a value read by Reader.ReadAny.
+type Value struct {
+	// Kind is the kind of the value.
+	Kind Kind
+
+	// Field is the field number of the value, or zero if none was
+	// written.
+	Field int
+
+	// Value is the value, with the type returned by the Read method of
+	// its kind, such as string for KindString and []int64 for KindInt64s.
+	// A nil string is nil, and the values of a sub-message are a []Value.
+	Value any
+}
+
+func (v Value) String() string {
+	var sb strings.Builder
+	if v.Field > 0 {
+		fmt.Fprintf(&sb, "%d:", v.Field)
+	}
+	sb.WriteString(v.Kind.String())
+	switch val := v.Value.(type) {
+	case []Value:
+		sb.WriteByte('{')
+		for i, elem := range val {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(elem.String())
+		}
+		sb.WriteByte('}')
+	case string, []string:
+		fmt.Fprintf(&sb, "(%q)", val)
+	default:
+		fmt.Fprintf(&sb, "(%v)", val)
+	}
+	return sb.String()
+}
+
+// ReadAny reads the next value of any kind from a tagged Reader, decoding
+// sub-messages into their values. It returns io.EOF at the end of the
+// input, so that a stream can be read without knowing its schema.
+func (r *Reader) ReadAny() (Value, error) {
+	if !r.tagged {
+		return Value{}, errors.New("bufrw: ReadAny of an untagged Reader")
+	}
+	return read(r, KindInvalid, r.readAny)
+}
+
+func (r *Reader) readAny() (Value, error) {
+	kind, field, err := r.nextTag()
+	if err != nil {
+		return Value{}, err
+	}
+	v := Value{Kind: kind, Field: field}
+	if kind != KindMessage {
+		v.Value, err = r.buf.readKind(r.r, kind)
+		return v, err
+	}
+	if err := r.buf.enter(); err != nil {
+		return Value{}, err
+	}
+	defer r.buf.leave()
+	sub, err := r.subReader()
+	if err != nil {
+		return Value{}, err
+	}
+	values := []Value{}
+	for sub.frame.N > 0 {
+		val, err := sub.readAny()
+		if err != nil {
+			return Value{}, err
+		}
+		values = append(values, val)
+	}
+	v.Value = values
+	return v, nil
+}
+
+// readKind reads a value of the given kind other than KindMessage.
+func (buf *Buffer) readKind(r io.Reader, kind Kind) (any, error) {
+	switch kind {
+	case KindBool:
+		return anyOf(buf.ReadBool(r))
+	case KindInt:
+		return anyOf(buf.ReadInt(r))
+	case KindInt8:
+		return anyOf(buf.ReadInt8(r))
+	case KindInt16:
+		return anyOf(buf.ReadInt16(r))
+	case KindInt32:
+		return anyOf(buf.ReadInt32(r))
+	case KindInt64:
+		return anyOf(buf.ReadInt64(r))
+	case KindUint8:
+		return anyOf(buf.ReadUint8(r))
+	case KindUint16:
+		return anyOf(buf.ReadUint16(r))
+	case KindUint32:
+		return anyOf(buf.ReadUint32(r))
+	case KindUint64:
+		return anyOf(buf.ReadUint64(r))
+	case KindFloat32:
+		return anyOf(buf.ReadFloat32(r))
+	case KindFloat64:
+		return anyOf(buf.ReadFloat64(r))
+	case KindComplex64:
+		return anyOf(buf.ReadComplex64(r))
+	case KindComplex128:
+		return anyOf(buf.ReadComplex128(r))
+	case KindUvarint:
+		return anyOf(buf.ReadUvarint(r))
+	case KindVarint:
+		return anyOf(buf.ReadVarint(r))
+	case KindString:
+		s, err := buf.ReadNullableString(r)
+		if err != nil || s == nil {
+			return nil, err
+		}
+		return *s, nil
+	case KindTime:
+		return anyOf(buf.ReadTime(r))
+	case KindDuration:
+		return anyOf(buf.ReadDuration(r))
+	case KindBools:
+		return anyOf(buf.ReadBools(r))
+	case KindInts:
+		return anyOf(buf.ReadInts(r))
+	case KindInt8s:
+		return anyOf(buf.ReadInt8s(r))
+	case KindInt16s:
+		return anyOf(buf.ReadInt16s(r))
+	case KindInt32s:
+		return anyOf(buf.ReadInt32s(r))
+	case KindInt64s:
+		return anyOf(buf.ReadInt64s(r))
+	case KindByteValues:
+		return anyOf(buf.ReadByteValues(r))
+	case KindUint16s:
+		return anyOf(buf.ReadUint16s(r))
+	case KindUint32s:
+		return anyOf(buf.ReadUint32s(r))
+	case KindUint64s:
+		return anyOf(buf.ReadUint64s(r))
+	case KindFloat32s:
+		return anyOf(buf.ReadFloat32s(r))
+	case KindFloat64s:
+		return anyOf(buf.ReadFloat64s(r))
+	case KindComplex64s:
+		return anyOf(buf.ReadComplex64s(r))
+	case KindComplex128s:
+		return anyOf(buf.ReadComplex128s(r))
+	case KindStrings:
+		return anyOf(buf.ReadStrings(r))
+	case KindPackedBools:
+		return anyOf(buf.ReadPackedBools(r))
+	case KindBitset:
+		return anyOf(buf.ReadBitset(r))
+	}
+	return nil, fmt.Errorf("bufrw: cannot read kind %v", kind)
+}
+
+// anyOf returns val as an any, or nil if err is not nil.
+func anyOf[T any](val T, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
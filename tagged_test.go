@@ -0,0 +1,175 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTaggedReadWrite(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	w := buf.TaggedWriter(&b)
+	w.WriteInt(42)
+	w.Field(2).WriteString("hello")
+	m := w.Field(3).BeginMessage()
+	m.WriteFloat64s(1.5, -2)
+	m.Field(1).WriteBool(true)
+	m.End()
+	w.WriteSerializable(&serializableString{"value"})
+	w.WriteLen(7)
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := buf.TaggedReader(&b)
+	i, _ := r.ReadInt()
+	s, _ := r.ReadString()
+	sub, _ := r.SubReader()
+	f, _ := sub.ReadFloat64s()
+	v, _ := sub.ReadBool()
+	var ser serializableString
+	r.ReadSerializable(&ser)
+	n, _ := r.ReadLen()
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if i != 42 || s != "hello" || !reflect.DeepEqual(f, []float64{1.5, -2}) || !v || ser.value != "VALUE" || n != 7 {
+		t.Errorf("Read = %v, %q, %v, %v, %q, %v", i, s, f, v, ser.value, n)
+	}
+	if b.Len() != 0 {
+		t.Errorf("Read left %d unread bytes", b.Len())
+	}
+}
+
+func TestTaggedReadAny(t *testing.T) {
+	var buf Buffer
+	buf.SetVarintLengths(true)
+	var b bytes.Buffer
+	w := buf.TaggedWriter(&b)
+	w.Field(1).WriteInt64(-7)
+	w.WriteStrings("a", "b")
+	w.WriteNullableString(nil)
+	m := w.Field(300).BeginMessage()
+	m.WriteUvarint(5)
+	m.BeginMessage().End()
+	m.End()
+	w.WriteByteValues(1, 2)
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Value{
+		{KindInt64, 1, int64(-7)},
+		{KindStrings, 0, []string{"a", "b"}},
+		{KindString, 0, nil},
+		{KindMessage, 300, []Value{{KindUvarint, 0, uint64(5)}, {KindMessage, 0, []Value{}}}},
+		{KindByteValues, 0, []byte{1, 2}},
+	}
+	r := buf.TaggedReader(&b)
+	var got []Value
+	for {
+		v, err := r.ReadAny()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadAny() = %v, want %v", got, want)
+	}
+	const wantString = `300:Message{Uvarint(5), Message{}}`
+	if s := want[3].String(); s != wantString {
+		t.Errorf("String() = %s, want %s", s, wantString)
+	}
+}
+
+func TestTaggedMismatch(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	if err := buf.TaggedWriter(&b).WriteInt(1); err != nil {
+		t.Fatal(err)
+	}
+	r := buf.TaggedReader(&b)
+	if _, err := r.ReadString(); !errors.Is(err, ErrTagMismatch) {
+		t.Errorf("ReadString() of int error is %v, want %v", err, ErrTagMismatch)
+	}
+}
+
+func TestTaggedReadTag(t *testing.T) {
+	// A reader that only knows fields 1 and 3 skips field 2.
+	var buf Buffer
+	var b bytes.Buffer
+	w := buf.TaggedWriter(&b)
+	w.Field(1).WriteString("name")
+	w.Field(2).WriteFloat64s(1, 2, 3)
+	w.Field(3).WriteInt32(9)
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+	r := buf.TaggedReader(&b)
+	var name string
+	var value int32
+	for {
+		kind, field, err := r.ReadTag()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch field {
+		case 1:
+			name, err = r.ReadString()
+		case 3:
+			value, err = r.ReadInt32()
+		default:
+			err = r.SkipKind(kind)
+		}
+		if err != nil {
+			t.Fatalf("Reading field %d: %v", field, err)
+		}
+	}
+	if name != "name" || value != 9 {
+		t.Errorf("Read fields = %q, %d, want %q, 9", name, value, "name")
+	}
+}
+
+func TestUntaggedReadAny(t *testing.T) {
+	var buf Buffer
+	if _, err := buf.Reader(bytes.NewReader(nil)).ReadAny(); err == nil {
+		t.Error("ReadAny() of untagged Reader succeeded")
+	}
+}
+
+func TestTaggedFieldRange(t *testing.T) {
+	var buf Buffer
+	for _, n := range []int{-1, math.MaxInt32 + 1} {
+		var b bytes.Buffer
+		w := buf.TaggedWriter(&b)
+		if err := w.Field(n).WriteInt(1); err == nil {
+			t.Errorf("WriteInt() with field number %d succeeded", n)
+		}
+		if b.Len() != 0 {
+			t.Errorf("WriteInt() with field number %d wrote %d bytes", n, b.Len())
+		}
+	}
+
+	var b bytes.Buffer
+	if err := buf.TaggedWriter(&b).Field(math.MaxInt32).WriteInt(1); err != nil {
+		t.Fatal(err)
+	}
+	kind, field, err := buf.TaggedReader(&b).ReadTag()
+	if kind != KindInt || field != math.MaxInt32 || err != nil {
+		t.Errorf("ReadTag() = %v, %d, %v, want %v, %d", kind, field, err, KindInt, math.MaxInt32)
+	}
+}
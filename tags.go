@@ -0,0 +1,219 @@
+package bufrw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/snechholt/bufrw/internal/tag"
+)
+
+// buildFieldCodec builds the codec of a struct field of type t with the
+// given options. Fields without options use the cached codec of t.
+func buildFieldCodec(t reflect.Type, opts tag.Options, building map[reflect.Type]*codec) (*codec, error) {
+	if opts == (tag.Options{}) {
+		return buildCodec(t, building)
+	}
+	if opts.Optional {
+		opts.Optional = false
+		value, err := buildFieldCodec(t, opts, building)
+		if err != nil {
+			return nil, err
+		}
+		return optionalCodec(t, value), nil
+	}
+	c := &codec{minSize: 1}
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem, err := buildFieldCodec(t.Elem(), opts, building)
+		if err != nil {
+			return nil, err
+		}
+		setPointerCodec(c, t, elem)
+	case reflect.Slice:
+		switch {
+		case opts.Packed && t.Elem().Kind() == reflect.Bool:
+			setPackedBoolsCodec(c, t, opts.Max)
+		case opts.Width == "" && !opts.Packed && t.Elem().Kind() == reflect.Uint8:
+			setBytesCodec(c, opts.Max)
+		default:
+			elem, err := buildFieldCodec(t.Elem(), tag.Options{Width: opts.Width, Packed: opts.Packed}, building)
+			if err != nil {
+				return nil, err
+			}
+			setSliceCodec(c, t, elem, opts.Max)
+		}
+	case reflect.Array:
+		if opts.Max > 0 {
+			return nil, errors.New("tag option max does not apply to arrays")
+		}
+		elem, err := buildFieldCodec(t.Elem(), opts, building)
+		if err != nil {
+			return nil, err
+		}
+		setArrayCodec(c, t, elem)
+	case reflect.Map:
+		elemOpts := tag.Options{Width: opts.Width, Packed: opts.Packed}
+		key, err := buildFieldCodec(t.Key(), tag.Options{Width: opts.Width}, building)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := buildFieldCodec(t.Elem(), elemOpts, building)
+		if err != nil {
+			return nil, err
+		}
+		setMapCodec(c, t, key, elem, opts.Max)
+	case reflect.String:
+		if opts.Width != "" || opts.Packed {
+			return nil, fmt.Errorf("tag options do not apply to %s", t)
+		}
+		setStringCodec(c, opts.Max)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if opts.Max > 0 || opts.Packed {
+			return nil, fmt.Errorf("tag options do not apply to %s", t)
+		}
+		if opts.Width == "" {
+			return buildCodec(t, building)
+		}
+		setIntCodec(c, t, opts.Width)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if opts.Max > 0 || opts.Packed {
+			return nil, fmt.Errorf("tag options do not apply to %s", t)
+		}
+		if opts.Width == "" {
+			return buildCodec(t, building)
+		}
+		setUintCodec(c, t, opts.Width)
+	default:
+		if opts.Width != "" || opts.Packed || opts.Max > 0 {
+			return nil, fmt.Errorf("tag options do not apply to %s", t)
+		}
+		return buildCodec(t, building)
+	}
+	return c, nil
+}
+
+// optionalCodec returns a codec writing a boolean telling whether a value
+// of type t is set, followed by the value encoded by value if it is.
+func optionalCodec(t reflect.Type, value *codec) *codec {
+	return &codec{
+		minSize: 1,
+		enc: func(buf *Buffer, w io.Writer, v reflect.Value) error {
+			if err := buf.WriteBool(w, !v.IsZero()); err != nil || v.IsZero() {
+				return err
+			}
+			return value.enc(buf, w, v)
+		},
+		dec: func(buf *Buffer, r io.Reader, v reflect.Value) error {
+			ok, err := buf.ReadBool(r)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				v.Set(reflect.Zero(t))
+				return nil
+			}
+			return value.dec(buf, r, v)
+		},
+	}
+}
+
+// setIntCodec sets c to encode signed integers of type t with the given
+// width option.
+func setIntCodec(c *codec, t reflect.Type, width string) {
+	if width == tag.Varint {
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteVarint(w, v.Int()) }
+	} else {
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteInt(w, int(v.Int())) }
+	}
+	c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+		var val int64
+		if width == tag.Varint {
+			var err error
+			if val, err = buf.ReadVarint(r); err != nil {
+				return err
+			}
+		} else {
+			n, err := buf.ReadInt(r)
+			if err != nil {
+				return err
+			}
+			val = int64(n)
+		}
+		if v.OverflowInt(val) {
+			return fmt.Errorf("bufrw: value %d overflows %s", val, t)
+		}
+		v.SetInt(val)
+		return nil
+	}
+}
+
+// setUintCodec sets c to encode unsigned integers of type t with the given
+// width option.
+func setUintCodec(c *codec, t reflect.Type, width string) {
+	if width == tag.Varint {
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error { return buf.WriteUvarint(w, v.Uint()) }
+	} else {
+		c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error {
+			if v.Uint() > 1<<32-1 {
+				return fmt.Errorf("bufrw: value %d overflows uint32", v.Uint())
+			}
+			return buf.WriteUint32(w, uint32(v.Uint()))
+		}
+	}
+	c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+		var val uint64
+		if width == tag.Varint {
+			var err error
+			if val, err = buf.ReadUvarint(r); err != nil {
+				return err
+			}
+		} else {
+			n, err := buf.ReadUint32(r)
+			if err != nil {
+				return err
+			}
+			val = uint64(n)
+		}
+		if v.OverflowUint(val) {
+			return fmt.Errorf("bufrw: value %d overflows %s", val, t)
+		}
+		v.SetUint(val)
+		return nil
+	}
+}
+
+// setPackedBoolsCodec sets c to encode bool slices of type t of at most
+// max elements with WritePackedBools.
+func setPackedBoolsCodec(c *codec, t reflect.Type, max int) {
+	c.enc = func(buf *Buffer, w io.Writer, v reflect.Value) error {
+		if err := checkLen(v.Len(), max); err != nil {
+			return err
+		}
+		var val []bool
+		if !v.IsNil() {
+			val = make([]bool, v.Len())
+		}
+		for i := range val {
+			val[i] = v.Index(i).Bool()
+		}
+		return buf.WritePackedBools(w, val...)
+	}
+	c.dec = func(buf *Buffer, r io.Reader, v reflect.Value) error {
+		n, b, err := buf.readBits(r, minLimit(max, buf.limits.MaxSliceLen))
+		if err != nil {
+			return err
+		}
+		if n == nilLen {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		s := reflect.MakeSlice(t, n, n)
+		for i := 0; i < n; i++ {
+			s.Index(i).SetBool(b[i/8]&(1<<(i%8)) != 0)
+		}
+		v.Set(s)
+		return nil
+	}
+}
@@ -0,0 +1,137 @@
+package bufrw
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type taggedRecord struct {
+	ID       int64    `bufrw:"varint"`
+	Port     uint16   `bufrw:"varint"`
+	Legacy   int      `bufrw:"fixed32"`
+	Count    uint64   `bufrw:"fixed32"`
+	Flags    []bool   `bufrw:"packed"`
+	Name     string   `bufrw:"max=8"`
+	Tags     []string `bufrw:"max=2"`
+	Sizes    []int32  `bufrw:"varint"`
+	Note     *string  `bufrw:"optional"`
+	Score    int64    `bufrw:"optional,varint"`
+	Internal string   `bufrw:"-"`
+	Default  int64
+}
+
+func TestMarshalTags(t *testing.T) {
+	note := "note"
+	value := taggedRecord{
+		ID:       300,
+		Port:     8080,
+		Legacy:   -5,
+		Count:    7,
+		Flags:    []bool{true, false, true},
+		Name:     "name",
+		Tags:     []string{"a", "b"},
+		Sizes:    []int32{-1, 1},
+		Note:     &note,
+		Internal: "internal",
+		Default:  9,
+	}
+
+	var buf Buffer
+	var want bytes.Buffer
+	buf.WriteVarint(&want, 300)
+	buf.WriteUvarint(&want, 8080)
+	buf.WriteInt(&want, -5)
+	buf.WriteUint32(&want, 7)
+	buf.WritePackedBools(&want, true, false, true)
+	buf.WriteString(&want, "name")
+	buf.WriteStrings(&want, "a", "b")
+	buf.WriteInt(&want, 2)
+	buf.WriteVarint(&want, -1)
+	buf.WriteVarint(&want, 1)
+	buf.WriteBool(&want, true)
+	buf.WriteBool(&want, true)
+	buf.WriteString(&want, "note")
+	buf.WriteBool(&want, false)
+	buf.WriteInt64(&want, 9)
+
+	var got bytes.Buffer
+	if err := buf.Marshal(&got, value); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Fatalf("Marshal = %x, want %x", got.Bytes(), want.Bytes())
+	}
+	var decoded taggedRecord
+	if err := buf.Unmarshal(&got, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	value.Internal = ""
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("Unmarshal = %+v, want %+v", decoded, value)
+	}
+}
+
+func TestMarshalTagsMax(t *testing.T) {
+	var buf Buffer
+	var w bytes.Buffer
+	if err := buf.Marshal(&w, taggedRecord{Name: "too long name"}); !errors.Is(err, ErrLengthExceeded) {
+		t.Errorf("Marshal of too long string: got error %v, want %v", err, ErrLengthExceeded)
+	}
+
+	type unlimited struct {
+		ID     int64  `bufrw:"varint"`
+		Port   uint16 `bufrw:"varint"`
+		Legacy int    `bufrw:"fixed32"`
+		Count  uint64 `bufrw:"fixed32"`
+		Flags  []bool `bufrw:"packed"`
+		Name   string
+		Tags   []string
+	}
+	w.Reset()
+	if err := buf.Marshal(&w, unlimited{Name: "abc", Tags: []string{"a", "b", "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := buf.Unmarshal(&w, &taggedRecord{}); !errors.Is(err, ErrLengthExceeded) {
+		t.Errorf("Unmarshal of too many strings: got error %v, want %v", err, ErrLengthExceeded)
+	}
+}
+
+func TestMarshalTagsInvalid(t *testing.T) {
+	tests := []interface{}{
+		struct {
+			A int `bufrw:"unknown"`
+		}{},
+		struct {
+			A string `bufrw:"varint"`
+		}{},
+		struct {
+			A []int `bufrw:"max=x"`
+		}{},
+		struct {
+			A int `bufrw:"packed"`
+		}{},
+	}
+	for _, test := range tests {
+		if err := Marshal(&bytes.Buffer{}, test); err == nil {
+			t.Errorf("Marshal(%T): expected error", test)
+		}
+	}
+}
+
+func TestUnmarshalTagsOverflow(t *testing.T) {
+	type wide struct {
+		A int64 `bufrw:"varint"`
+	}
+	type narrow struct {
+		A int8 `bufrw:"varint"`
+	}
+	var w bytes.Buffer
+	if err := Marshal(&w, wide{1000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal(&w, &narrow{}); err == nil {
+		t.Error("Unmarshal of overflowing value: expected error")
+	}
+}
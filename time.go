@@ -0,0 +1,136 @@
+package bufrw
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TimeMode selects how a Buffer encodes time.Time values. The monotonic
+// clock reading of a time is never written.
+type TimeMode int
+
+const (
+	// TimeSecondsNanos writes a time as its seconds since the Unix epoch
+	// with WriteInt64, followed by its nanoseconds within the second with
+	// WriteUint32. It covers the full range of time.Time. Times are read
+	// in UTC. This is the default mode.
+	TimeSecondsNanos TimeMode = iota
+
+	// TimeUnixNano writes a time as its nanoseconds since the Unix epoch
+	// with WriteInt64. It only covers the years 1678 to 2262. Times are
+	// read in UTC.
+	TimeUnixNano
+
+	// TimeLocation writes a time like TimeSecondsNanos, followed by the
+	// name of its location and its offset from UTC in seconds. Times are
+	// read in the named location if it can be loaded and agrees with the
+	// offset, and otherwise in a fixed zone with the offset.
+	TimeLocation
+)
+
+var (
+	minUnixNano = time.Unix(0, -1<<63)
+	maxUnixNano = time.Unix(0, 1<<63-1)
+
+	locations sync.Map // map[string]*time.Location
+)
+
+// SetTimeMode sets how the buffer encodes time.Time values. Readers must
+// use the same mode as the writer.
+func (buf *Buffer) SetTimeMode(mode TimeMode) {
+	buf.timeMode = mode
+}
+
+// WriteTime writes a time.Time value to w, encoded according to the time
+// mode of the buffer.
+func (buf *Buffer) WriteTime(w io.Writer, val time.Time) error {
+	if buf.timeMode == TimeUnixNano {
+		if val.Before(minUnixNano) || val.After(maxUnixNano) {
+			return fmt.Errorf("bufrw: time %v out of range of TimeUnixNano", val)
+		}
+		return buf.WriteInt64(w, val.UnixNano())
+	}
+	if err := buf.WriteInt64(w, val.Unix()); err != nil {
+		return err
+	}
+	if err := buf.WriteUint32(w, uint32(val.Nanosecond())); err != nil {
+		return err
+	}
+	if buf.timeMode != TimeLocation {
+		return nil
+	}
+	_, offset := val.Zone()
+	if err := buf.WriteString(w, val.Location().String()); err != nil {
+		return err
+	}
+	return buf.WriteInt32(w, int32(offset))
+}
+
+// ReadTime reads a time.Time value from r, where r reads from a source
+// that has used WriteTime to write a time.Time value.
+func (buf *Buffer) ReadTime(r io.Reader) (time.Time, error) {
+	if buf.timeMode == TimeUnixNano {
+		n, err := buf.ReadInt64(r)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, n).UTC(), nil
+	}
+	sec, err := buf.ReadInt64(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	nsec, err := buf.ReadUint32(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if nsec >= 1e9 {
+		return time.Time{}, fmt.Errorf("bufrw: invalid nanoseconds %d", nsec)
+	}
+	t := time.Unix(sec, int64(nsec)).UTC()
+	if buf.timeMode != TimeLocation {
+		return t, nil
+	}
+	name, err := buf.ReadString(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	offset, err := buf.ReadInt32(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(location(t, name, int(offset))), nil
+}
+
+// location returns the location with the given name if it can be loaded
+// and has the given offset at time t, and otherwise a fixed zone with the
+// offset. Loaded locations are cached.
+func location(t time.Time, name string, offset int) *time.Location {
+	var loc *time.Location
+	if l, ok := locations.Load(name); ok {
+		loc = l.(*time.Location)
+	} else if l, err := time.LoadLocation(name); err == nil {
+		locations.Store(name, l)
+		loc = l
+	}
+	if loc != nil {
+		if _, off := t.In(loc).Zone(); off == offset {
+			return loc
+		}
+	}
+	return time.FixedZone(name, offset)
+}
+
+// WriteDuration writes a time.Duration value to w with WriteInt64.
+func (buf *Buffer) WriteDuration(w io.Writer, val time.Duration) error {
+	return buf.WriteInt64(w, int64(val))
+}
+
+// ReadDuration reads a time.Duration value from r, where r reads from a
+// source that has used WriteDuration to write a time.Duration value.
+func (buf *Buffer) ReadDuration(r io.Reader) (time.Duration, error) {
+	val, err := buf.ReadInt64(r)
+	return time.Duration(val), err
+}
@@ -0,0 +1,160 @@
+package bufrw
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBufferReadWriteTime(t *testing.T) {
+	values := []time.Time{
+		{},
+		time.Unix(0, 0).UTC(),
+		time.Date(2024, 2, 29, 12, 30, 15, 123456789, time.UTC),
+		time.Date(1, 1, 1, 0, 0, 0, 1, time.UTC),
+		time.Date(1200, 6, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 999999999, time.UTC),
+	}
+	for _, mode := range []TimeMode{TimeSecondsNanos, TimeLocation} {
+		testTimes(t, mode, values)
+	}
+	testTimes(t, TimeUnixNano, values[1:3])
+}
+
+func testTimes(t *testing.T, mode TimeMode, values []time.Time) {
+	t.Helper()
+	for _, value := range values {
+		var buf Buffer
+		buf.SetTimeMode(mode)
+		var b bytes.Buffer
+		if err := buf.WriteTime(&b, value); err != nil {
+			t.Fatal(err)
+		}
+		got, err := buf.ReadTime(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(value) || got.Location() != time.UTC {
+			t.Errorf("Mode %d: Write/Read %v = %v", mode, value, got)
+		}
+	}
+}
+
+func TestBufferTimeMonotonic(t *testing.T) {
+	now := time.Now()
+	var buf Buffer
+	var b bytes.Buffer
+	if err := buf.WriteTime(&b, now); err != nil {
+		t.Fatal(err)
+	}
+	got, err := buf.ReadTime(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := now.Round(0).UTC(); got != want {
+		t.Errorf("Write/Read %v = %v, want %v", now, got, want)
+	}
+}
+
+func TestBufferTimeUnixNanoRange(t *testing.T) {
+	var buf Buffer
+	buf.SetTimeMode(TimeUnixNano)
+	var b bytes.Buffer
+	for _, value := range []time.Time{time.Date(1600, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC)} {
+		if err := buf.WriteTime(&b, value); err == nil {
+			t.Errorf("WriteTime(%v) with TimeUnixNano succeeded", value)
+		}
+	}
+}
+
+func TestBufferTimeLocation(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone database not available: %v", err)
+	}
+	tests := []struct {
+		value    time.Time
+		location string
+	}{
+		{time.Date(2024, 7, 1, 12, 0, 0, 0, ny), "America/New_York"},
+		{time.Date(2024, 1, 1, 12, 0, 0, 0, ny), "America/New_York"},
+		{time.Date(2024, 1, 1, 12, 0, 0, 0, time.FixedZone("custom", 5400)), "custom"},
+		{time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), "UTC"},
+	}
+	for _, test := range tests {
+		var buf Buffer
+		buf.SetTimeMode(TimeLocation)
+		var b bytes.Buffer
+		if err := buf.WriteTime(&b, test.value); err != nil {
+			t.Fatal(err)
+		}
+		got, err := buf.ReadTime(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, wantOffset := test.value.Zone()
+		_, gotOffset := got.Zone()
+		if !got.Equal(test.value) || got.Location().String() != test.location || gotOffset != wantOffset {
+			t.Errorf("Write/Read %v = %v in %s", test.value, got, got.Location())
+		}
+	}
+}
+
+func TestBufferTimeLocationMismatch(t *testing.T) {
+	// A location whose offset disagrees with the written offset, such as
+	// one whose rules changed, is replaced by a fixed zone.
+	var buf Buffer
+	buf.SetTimeMode(TimeLocation)
+	var b bytes.Buffer
+	value := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := buf.Writer(&b)
+	w.WriteInt64(value.Unix())
+	w.WriteUint32(0)
+	w.WriteString("UTC")
+	w.WriteInt32(3600)
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := buf.Reader(&b).ReadTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, offset := got.Zone(); !got.Equal(value) || name != "UTC" || offset != 3600 {
+		t.Errorf("ReadTime() = %v, want %v in fixed zone UTC+1", got, value)
+	}
+}
+
+func TestBufferReadTimeInvalidNanos(t *testing.T) {
+	var buf Buffer
+	var b bytes.Buffer
+	if err := buf.WriteInt64(&b, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := buf.WriteUint32(&b, 1e9); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buf.ReadTime(&b); err == nil {
+		t.Error("ReadTime() with invalid nanoseconds succeeded")
+	}
+}
+
+func TestBufferReadWriteDuration(t *testing.T) {
+	values := []time.Duration{0, time.Nanosecond, -time.Hour, 1<<63 - 1, -1 << 63}
+	testReadWrite(t, values, (*Buffer).WriteDuration, (*Buffer).ReadDuration)
+}
+
+func TestMarshalTime(t *testing.T) {
+	type event struct {
+		At       time.Time
+		Duration time.Duration
+	}
+	value := event{time.Date(2024, 2, 29, 12, 30, 15, 123456789, time.UTC), time.Minute}
+	testReadWrite(t, []event{{}, value}, func(buf *Buffer, w io.Writer, val event) error {
+		return buf.Marshal(w, val)
+	}, func(buf *Buffer, r io.Reader) (event, error) {
+		var val event
+		err := buf.Unmarshal(r, &val)
+		return val, err
+	})
+}
@@ -0,0 +1,116 @@
+package bufrw
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrVarintOverflow is returned when a varint read from the input does
+// not fit in a 64-bit integer.
+var ErrVarintOverflow = errors.New("bufrw: varint overflows a 64-bit integer")
+
+// WriteUvarint writes a uint64 value to w using between 1 and 10 bytes,
+// compatible with binary.PutUvarint.
+func (buf *Buffer) WriteUvarint(w io.Writer, val uint64) error {
+	b := buf.borrow(binary.MaxVarintLen64)
+	n := binary.PutUvarint(b, val)
+	_, err := w.Write(b[:n])
+	return err
+}
+
+// ReadUvarint reads a uint64 value from r, where r reads from a source
+// that has used WriteUvarint to write a uint64 value.
+func (buf *Buffer) ReadUvarint(r io.Reader) (uint64, error) {
+	var val uint64
+	var shift uint
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		c, err := buf.ReadByteValue(r)
+		if err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if c < 0x80 {
+			if i == binary.MaxVarintLen64-1 && c > 1 {
+				return 0, ErrVarintOverflow
+			}
+			return val | uint64(c)<<shift, nil
+		}
+		val |= uint64(c&0x7f) << shift
+		shift += 7
+	}
+	return 0, ErrVarintOverflow
+}
+
+// WriteVarint writes an int64 value to w using between 1 and 10 bytes,
+// compatible with binary.PutVarint. Values close to zero, whether positive
+// or negative, use the fewest bytes.
+func (buf *Buffer) WriteVarint(w io.Writer, val int64) error {
+	b := buf.borrow(binary.MaxVarintLen64)
+	n := binary.PutVarint(b, val)
+	_, err := w.Write(b[:n])
+	return err
+}
+
+// ReadVarint reads an int64 value from r, where r reads from a source
+// that has used WriteVarint to write an int64 value.
+func (buf *Buffer) ReadVarint(r io.Reader) (int64, error) {
+	u, err := buf.ReadUvarint(r)
+	val := int64(u >> 1)
+	if u&1 != 0 {
+		val = ^val
+	}
+	return val, err
+}
+
+// SetVarintLengths sets whether the buffer encodes the length prefixes of
+// strings and slices with WriteVarint rather than WriteInt. Readers must
+// use the same setting as the writer.
+func (buf *Buffer) SetVarintLengths(varint bool) {
+	buf.varintLengths = varint
+}
+
+// WriteLen writes the length prefix of a collection of n values to w, as
+// written by the plural methods of the buffer. The length is written with
+// WriteInt, or with WriteVarint if the buffer uses varint lengths.
+func (buf *Buffer) WriteLen(w io.Writer, n int) error {
+	if buf.varintLengths {
+		return buf.WriteVarint(w, int64(n))
+	}
+	return buf.WriteInt(w, n)
+}
+
+// lenSize returns the minimum size of a length prefix written by WriteLen.
+func (buf *Buffer) lenSize() int {
+	if buf.varintLengths {
+		return 1
+	}
+	return 4
+}
+
+// ReadLen reads the length prefix of a collection from r, where r reads
+// from a source that has used WriteLen to write the length. The length is
+// validated against the MaxSliceLen limit of the buffer.
+func (buf *Buffer) ReadLen(r io.Reader) (int, error) {
+	return buf.readLen(r, buf.limits.MaxSliceLen, 1)
+}
+
+// readLenPrefix reads a length prefix from r, where r reads from a source
+// that has used WriteLen to write the length.
+func (buf *Buffer) readLenPrefix(r io.Reader) (int, error) {
+	if !buf.varintLengths {
+		return buf.ReadInt(r)
+	}
+	n, err := buf.ReadVarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxInt32 || n < math.MinInt32 {
+		return 0, fmt.Errorf("bufrw: length %d: %w", n, ErrLengthExceeded)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,96 @@
+package bufrw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBufferReadWriteUvarint(t *testing.T) {
+	values := []uint64{0, 1, 127, 128, 300, math.MaxUint32, math.MaxInt64, math.MaxUint64}
+	testReadWrite(t, values, (*Buffer).WriteUvarint, (*Buffer).ReadUvarint)
+	for _, value := range values {
+		var buf Buffer
+		var w bytes.Buffer
+		if err := buf.WriteUvarint(&w, value); err != nil {
+			t.Fatal(err)
+		}
+		if want := binary.AppendUvarint(nil, value); !bytes.Equal(w.Bytes(), want) {
+			t.Errorf("WriteUvarint(%v) = %x, want %x", value, w.Bytes(), want)
+		}
+	}
+}
+
+func TestBufferReadWriteVarint(t *testing.T) {
+	values := []int64{math.MinInt64, -300, -64, -1, 0, 1, 63, 300, math.MaxInt64}
+	testReadWrite(t, values, (*Buffer).WriteVarint, (*Buffer).ReadVarint)
+	for _, value := range values {
+		var buf Buffer
+		var w bytes.Buffer
+		if err := buf.WriteVarint(&w, value); err != nil {
+			t.Fatal(err)
+		}
+		if want := binary.AppendVarint(nil, value); !bytes.Equal(w.Bytes(), want) {
+			t.Errorf("WriteVarint(%v) = %x, want %x", value, w.Bytes(), want)
+		}
+	}
+}
+
+func TestBufferReadUvarintErrors(t *testing.T) {
+	tests := []struct {
+		input []byte
+		want  error
+	}{
+		{[]byte{}, io.EOF},
+		{[]byte{0x80}, io.ErrUnexpectedEOF},
+		{bytes.Repeat([]byte{0xff}, 10), ErrVarintOverflow},
+		{append(bytes.Repeat([]byte{0xff}, 9), 0x02), ErrVarintOverflow},
+	}
+	for _, test := range tests {
+		var buf Buffer
+		if _, err := buf.ReadUvarint(bytes.NewReader(test.input)); !errors.Is(err, test.want) {
+			t.Errorf("ReadUvarint(%x) error = %v, want %v", test.input, err, test.want)
+		}
+	}
+}
+
+func TestBufferVarintLengths(t *testing.T) {
+	var buf Buffer
+	buf.SetVarintLengths(true)
+	var w bytes.Buffer
+	if err := buf.WriteStrings(&w, "a", "bc"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{4, 2, 'a', 4, 'b', 'c'}; !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("WriteStrings = %x, want %x", w.Bytes(), want)
+	}
+	if err := buf.WriteInts(&w, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := buf.WriteByteValues(&w, 1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := buf.WriteUint16s(&w, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := buf.ReadStrings(&w); err != nil || !reflect.DeepEqual(got, []string{"a", "bc"}) {
+		t.Errorf("ReadStrings() = %v, %v", got, err)
+	}
+	if got, err := buf.ReadInts(&w); err != nil || !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("ReadInts() = %v, %v", got, err)
+	}
+	if got, err := buf.ReadByteValues(&w); err != nil || !reflect.DeepEqual(got, []byte{1, 2, 3}) {
+		t.Errorf("ReadByteValues() = %v, %v", got, err)
+	}
+	if got, err := buf.ReadUint16s(&w); err != nil || !reflect.DeepEqual(got, []uint16{1, 2}) {
+		t.Errorf("ReadUint16s() = %v, %v", got, err)
+	}
+	if w.Len() != 0 {
+		t.Errorf("%d unread bytes", w.Len())
+	}
+}